@@ -6,9 +6,7 @@ require (
 	github.com/creack/pty v1.1.21
 	github.com/muesli/cancelreader v0.2.2
 	github.com/peterh/liner v1.2.2
+	golang.org/x/sys v0.23.0
 )
 
-require (
-	github.com/mattn/go-runewidth v0.0.3 // indirect
-	golang.org/x/sys v0.23.0 // indirect
-)
+require github.com/mattn/go-runewidth v0.0.3 // indirect
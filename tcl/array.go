@@ -0,0 +1,202 @@
+/*
+ * TCL  Array command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+var arrayMap = map[string]func(*Tcl, []string) int{
+	"exists": arrayExists, // arrayName
+	"get":    arrayGet,    // arrayName ?pattern
+	"names":  arrayNames,  // arrayName ?pattern
+	"set":    arraySet,    // arrayName list
+	"size":   arraySize,   // arrayName
+	"unset":  arrayUnset,  // arrayName ?pattern
+}
+
+// Array command.
+func cmdArray(tcl *Tcl, args []string) int {
+	if len(args) < 3 {
+		return tcl.SetResult(RetError, "array subcommand arrayName ?arg ...")
+	}
+	fn, ok := arrayMap[args[1]]
+	if !ok {
+		return tcl.SetResult(RetError, "array unknown subcommand "+args[1])
+	}
+	return fn(tcl, args)
+}
+
+// Return variable holding array, nil if not an array.
+func (tcl *Tcl) findArray(name string) *tclVar {
+	vars, base := tcl.varTable(name)
+	variable, ok := vars[base]
+	if !ok || variable.array == nil || variable.undefined {
+		return nil
+	}
+	if variable == tcl.environ {
+		tcl.loadEnviron()
+	}
+	return variable
+}
+
+// Return sorted names of array elements matching pattern.
+func (variable *tclVar) elementNames(pattern string) []string {
+	names := []string{}
+	for name, element := range variable.array {
+		if element.undefined {
+			continue
+		}
+		if pattern == "" || globMatch(pattern, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Return 1 if variable is an array.
+func arrayExists(tcl *Tcl, args []string) int {
+	if len(args) != 3 {
+		return tcl.SetResult(RetError, "array exists arrayName")
+	}
+	if tcl.findArray(args[2]) == nil {
+		return tcl.SetResult(RetOk, "0")
+	}
+	return tcl.SetResult(RetOk, "1")
+}
+
+// Return list of element names and values.
+func arrayGet(tcl *Tcl, args []string) int {
+	if len(args) != 3 && len(args) != 4 {
+		return tcl.SetResult(RetError, "array get arrayName ?pattern")
+	}
+	variable := tcl.findArray(args[2])
+	if variable == nil {
+		return tcl.SetResult(RetOk, "")
+	}
+	pattern := ""
+	if len(args) == 4 {
+		pattern = args[3]
+	}
+	list := []string{}
+	for _, name := range variable.elementNames(pattern) {
+		list = append(list, StringEscape(name), StringEscape(variable.array[name].value))
+	}
+	return tcl.SetResult(RetOk, strings.Join(list, " "))
+}
+
+// Return list of element names.
+func arrayNames(tcl *Tcl, args []string) int {
+	if len(args) != 3 && len(args) != 4 {
+		return tcl.SetResult(RetError, "array names arrayName ?pattern")
+	}
+	variable := tcl.findArray(args[2])
+	if variable == nil {
+		return tcl.SetResult(RetOk, "")
+	}
+	pattern := ""
+	if len(args) == 4 {
+		pattern = args[3]
+	}
+	return tcl.SetResult(RetOk, escapeList(variable.elementNames(pattern)))
+}
+
+// Set elements of array from list of names and values.
+func arraySet(tcl *Tcl, args []string) int {
+	if len(args) != 4 {
+		return tcl.SetResult(RetError, "array set arrayName list")
+	}
+	list := []string{}
+	if strings.TrimSpace(args[3]) != "" {
+		list = tcl.ParseArgs(args[3])
+	}
+	if len(list)%2 != 0 {
+		return tcl.SetResult(RetError, "list must have an even number of elements")
+	}
+
+	// Create array, even if list is empty.
+	vars, base := tcl.varTable(args[2])
+	if vars == nil {
+		return tcl.SetResult(RetError, "can't set \""+args[2]+"\": parent namespace doesn't exist")
+	}
+	variable, ok := vars[base]
+	if !ok {
+		vars[base] = &tclVar{array: make(map[string]*tclVar)}
+	} else if variable.array == nil {
+		return tcl.SetResult(RetError, "can't set \""+args[2]+"\": variable isn't array")
+	}
+
+	for i := 0; i < len(list); i += 2 {
+		if ret, msg := tcl.setVar(args[2]+"("+list[i]+")", list[i+1]); ret != RetOk {
+			return tcl.SetResult(ret, msg)
+		}
+	}
+	return tcl.SetResult(RetOk, "")
+}
+
+// Return number of elements in array.
+func arraySize(tcl *Tcl, args []string) int {
+	if len(args) != 3 {
+		return tcl.SetResult(RetError, "array size arrayName")
+	}
+	variable := tcl.findArray(args[2])
+	if variable == nil {
+		return tcl.SetResult(RetOk, "0")
+	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(len(variable.elementNames("")), 10))
+}
+
+// Remove array, or elements of array matching pattern.
+func arrayUnset(tcl *Tcl, args []string) int {
+	if len(args) != 3 && len(args) != 4 {
+		return tcl.SetResult(RetError, "array unset arrayName ?pattern")
+	}
+	variable := tcl.findArray(args[2])
+	if variable == nil {
+		return tcl.SetResult(RetOk, "")
+	}
+	// Unset each element of env so it stays linked to the environment.
+	if len(args) == 3 && variable != tcl.environ {
+		tcl.UnSetVar(args[2])
+		return tcl.SetResult(RetOk, "")
+	}
+	pattern := ""
+	if len(args) == 4 {
+		pattern = args[3]
+	}
+	for _, name := range variable.elementNames(pattern) {
+		element := variable.array[name]
+		delete(variable.array, name)
+		if variable == tcl.environ {
+			_ = os.Unsetenv(name)
+		}
+		tcl.traceVar(variable, element, args[2], name, "unset")
+	}
+	return tcl.SetResult(RetOk, "")
+}
@@ -0,0 +1,101 @@
+/*
+ * TCL  Auto loading of commands.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Look for name.tcl in each directory of auto_path, and source it at global level.
+// Returns command if it is defined after loading, otherwise result holds the error.
+func (tcl *Tcl) autoLoad(name string) (*tclCmd, bool) {
+	tcl.result = "unable to find command: " + name
+	for _, dir := range tcl.autoPath() {
+		text, err := os.ReadFile(filepath.Join(dir, name+".tcl"))
+		if err != nil {
+			continue
+		}
+
+		if ret := tcl.evalGlobal(string(text)); ret == RetError {
+			return nil, false
+		}
+
+		if cmd, ok := tcl.findCommand(name); ok {
+			tcl.result = ""
+			return cmd, true
+		}
+		tcl.result = "unable to find command: " + name
+	}
+	return nil, false
+}
+
+// Return directories listed in global variable auto_path.
+func (tcl *Tcl) autoPath() []string {
+	variable, ok := tcl.global.vars["auto_path"]
+	if !ok || variable.array != nil {
+		return nil
+	}
+	dirs := []string{}
+	for _, dir := range tcl.ParseArgs(variable.value) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Source pkgIndex.tcl from each directory of auto_path and their subdirectories.
+// Each index is evaluated at global level with local variable dir set to the
+// directory holding it, so it can give package ifneeded scripts relative to it.
+func (tcl *Tcl) loadPackageIndexes() int {
+	for _, dir := range tcl.autoPath() {
+		indexes := []string{filepath.Join(dir, "pkgIndex.tcl")}
+		subIndexes, _ := filepath.Glob(filepath.Join(dir, "*", "pkgIndex.tcl"))
+		for _, index := range append(indexes, subIndexes...) {
+			text, err := os.ReadFile(index)
+			if err != nil {
+				continue
+			}
+
+			saveEnv, saveLevel := tcl.env, tcl.level
+			for tcl.env.parent != nil {
+				tcl.env = tcl.env.parent
+			}
+			tcl.level = tcl.env.level
+			newenv := tcl.newEnv()
+			newenv.ns = tcl.global
+			tcl.setVarNewEnv(newenv, "dir", filepath.Dir(index), true)
+			tcl.pushEnv(newenv)
+			ret := tcl.eval(string(text), parserOptions{})
+			tcl.env, tcl.level = saveEnv, saveLevel
+			if ret == RetError {
+				return ret
+			}
+		}
+	}
+	return RetOk
+}
@@ -27,6 +27,8 @@ package tcl
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"regexp"
 	"strings"
 	"unicode"
@@ -34,79 +36,240 @@ import (
 
 // Register commands.
 func (tcl *Tcl) tclInitCommands() {
+	tcl.Register("after", cmdAfter)
 	tcl.Register("append", cmdAppend)
+	tcl.Register("apply", cmdApply)
+	tcl.Register("array", cmdArray)
+	tcl.Register("binary", cmdBinary)
 	tcl.Register("break", func(_ *Tcl, _ []string) int { return RetBreak })
 	tcl.Register("catch", cmdCatch)
+	tcl.Register("clock", cmdClock)
 	tcl.Register("concat", cmdConcat)
+	tcl.Register("coroutine", cmdCoroutine)
 	tcl.Register("continue", func(_ *Tcl, _ []string) int { return RetContinue })
 	tcl.Register("decr", cmdDecr)
+	tcl.Register("dict", cmdDict)
 	tcl.Register("eq", cmdEqual)
 	tcl.Register("error", cmdError)
 	tcl.Register("eval", cmdEval)
+	tcl.Register("exec", cmdExec)
 	tcl.Register("exit", cmdExit)
 	tcl.Register("expr", cmdMath)
 	tcl.Register("for", cmdFor)
 	tcl.Register("foreach", cmdForEach)
+	tcl.Register("format", cmdFormat)
 	tcl.Register("global", cmdGlobal)
 	tcl.Register("if", cmdIf)
 	tcl.Register("info", cmdInfo)
 	tcl.Register("incr", cmdIncr)
 	tcl.Register("join", cmdJoin)
 	tcl.Register("lappend", cmdLAppend)
+	tcl.Register("lassign", cmdLAssign)
 	tcl.Register("lindex", cmdLIndex)
 	tcl.Register("linsert", cmdLInsert)
 	tcl.Register("list", cmdList)
 	tcl.Register("llength", cmdLLength)
+	tcl.Register("lmap", cmdLMap)
 	tcl.Register("lrange", cmdLRange)
+	tcl.Register("lrepeat", cmdLRepeat)
 	tcl.Register("lreplace", cmdLReplace)
+	tcl.Register("lreverse", cmdLReverse)
 	tcl.Register("lsearch", cmdLSearch)
 	tcl.Register("lset", cmdLSet)
 	tcl.Register("lsort", cmdLSort)
+	tcl.Register("namespace", cmdNamespace)
 	tcl.Register("ne", cmdNotEqual)
+	tcl.Register("package", cmdPackage)
 	tcl.Register("proc", cmdProc)
 	tcl.Register("puts", cmdPuts)
+	tcl.Register("regexp", cmdRegexp)
+	tcl.Register("regsub", cmdRegsub)
 	tcl.Register("rename", cmdRename)
 	tcl.Register("return", cmdReturn)
+	tcl.Register("scan", cmdScan)
 	tcl.Register("set", cmdSet)
 	tcl.Register("split", cmdSplit)
 	tcl.Register("string", cmdString)
 	tcl.Register("subst", cmdSubst)
 	tcl.Register("switch", cmdSwitch)
+	tcl.Register("throw", cmdThrow)
+	tcl.Register("trace", cmdTrace)
+	tcl.Register("try", cmdTry)
 	tcl.Register("uplevel", cmdUpLevel)
+	tcl.Register("update", cmdUpdate)
 	tcl.Register("upvar", cmdUpVar)
 	tcl.Register("unset", cmdUnSet)
 	tcl.Register("variable", cmdVariable)
+	tcl.Register("vwait", cmdVWait)
 	tcl.Register("while", cmdWhile)
+	tcl.Register("yield", cmdYield)
 }
 
 // Register a command. Arg is passed to function when called.
 func (tcl *Tcl) Register(name string, fn func(*Tcl, []string) int) {
-	tcl.cmds[name] = &tclCmd{fn: fn, proc: false}
+	tcl.cmds[tcl.prefix+name] = &tclCmd{fn: fn, proc: false}
 }
 
-// Evaluate an argument, and catch any errors.
+// Evaluate an argument, and catch any errors. Returns completion code of
+// script, exit is not caught.
 func cmdCatch(tcl *Tcl, args []string) int {
-	if len(args) < 2 || len(args) > 3 {
-		return tcl.SetResult(RetError, "catch script ?varName")
+	if len(args) < 2 || len(args) > 4 {
+		return tcl.SetResult(RetError, "catch script ?resultVar ?optionsVar")
 	}
 	ret := tcl.eval(args[1], parserOptions{})
-	if len(args) == 3 {
-		tcl.SetVarValue(args[2], tcl.result)
+	if ret == RetExit {
+		return ret
 	}
-	if ret != RetOk {
-		return tcl.SetResult(RetOk, "1")
+	tcl.inError = false
+	if len(args) > 2 {
+		if r, msg := tcl.setVar(args[2], tcl.result); r != RetOk {
+			return tcl.SetResult(r, msg)
+		}
+	}
+	if len(args) > 3 {
+		if r, msg := tcl.setVar(args[3], tcl.returnOptions(ret)); r != RetOk {
+			return tcl.SetResult(r, msg)
+		}
 	}
-	return tcl.SetResult(RetOk, "0")
+	return tcl.SetResult(RetOk, ConvertNumberToString(ret, 10))
 }
 
-// Return Error condition.
+// Return options dictionary describing how script completed.
+func (tcl *Tcl) returnOptions(ret int) string {
+	options := []string{"-code", ConvertNumberToString(ret, 10), "-level", "0"}
+	if ret == RetReturn {
+		// Return command always sets at least level 1.
+		state := tcl.returning
+		options = []string{"-code", ConvertNumberToString(state.code, 10), "-level", ConvertNumberToString(max(state.level, 1), 10)}
+		if state.code == RetError && state.errorCode != "" {
+			options = append(options, "-errorcode", state.errorCode)
+		}
+	}
+	if ret == RetError {
+		_, errorCode := tcl.GetVarValue("::errorCode")
+		_, errorInfo := tcl.GetVarValue("::errorInfo")
+		options = append(options, "-errorcode", errorCode, "-errorinfo", errorInfo)
+	}
+	return escapeList(options)
+}
+
+// Return Error condition, info starts errorInfo and code sets errorCode.
 func cmdError(tcl *Tcl, args []string) int {
-	if len(args) != 2 {
-		return tcl.SetResult(RetError, "error message")
+	if len(args) < 2 || len(args) > 4 {
+		return tcl.SetResult(RetError, "error message ?info ?code")
+	}
+	if len(args) > 2 && args[2] != "" {
+		tcl.setVar("::errorInfo", args[2])
+		tcl.inError = true
+	}
+	if len(args) > 3 {
+		tcl.SetErrorCode(args[3])
 	}
 	return tcl.SetResult(RetError, args[1])
 }
 
+// Names of completion codes for try handlers.
+var tryCodes = map[string]int{
+	"ok":       RetOk,
+	"error":    RetError,
+	"return":   RetReturn,
+	"break":    RetBreak,
+	"continue": RetContinue,
+}
+
+// Raise an error with type stored in errorCode.
+func cmdThrow(tcl *Tcl, args []string) int {
+	if len(args) != 3 {
+		return tcl.SetResult(RetError, "throw type message")
+	}
+	if strings.TrimSpace(args[1]) == "" {
+		return tcl.SetResult(RetError, "type must be non-empty list")
+	}
+	tcl.SetErrorCode(args[1])
+	return tcl.SetResult(RetError, args[2])
+}
+
+// Evaluate body, run handler matching how it completed, then finally script.
+func cmdTry(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "try body ?handler ...? ?finally script")
+	}
+	handlers := args[2:]
+	finally := ""
+	if len(handlers) >= 2 && handlers[len(handlers)-2] == "finally" {
+		finally = handlers[len(handlers)-1]
+		handlers = handlers[:len(handlers)-2]
+	}
+
+	tcl.setVar("::errorCode", "NONE")
+	ret := tcl.eval(args[1], parserOptions{})
+	ret, result := tcl.tryHandlers(handlers, ret, tcl.result)
+
+	// Error in finally replaces result of body.
+	if finally != "" {
+		if fret := tcl.eval(finally, parserOptions{}); fret != RetOk {
+			return fret
+		}
+	}
+	return tcl.SetResult(ret, result)
+}
+
+// Run first handler that matches completion code, returns new code and result.
+func (tcl *Tcl) tryHandlers(handlers []string, ret int, result string) (int, string) {
+	_, errorCode := tcl.GetVarValue("::errorCode")
+	for i := 0; i < len(handlers); i += 4 {
+		if handlers[i] != "on" && handlers[i] != "trap" {
+			return RetError, "bad handler \"" + handlers[i] + "\": must be \"on\", \"trap\", or \"finally\""
+		}
+		if i+3 >= len(handlers) {
+			return RetError, "wrong # args to " + handlers[i] + " clause: must be \"" + handlers[i] + " pattern varList script\""
+		}
+
+		matched := false
+		if handlers[i] == "on" {
+			code, ok := tryCodes[handlers[i+1]]
+			if !ok {
+				code, ok = formatInteger(handlers[i+1])
+			}
+			if !ok {
+				return RetError, "bad completion code \"" + handlers[i+1] + "\": must be ok, error, return, break, continue, or an integer"
+			}
+			matched = code == ret
+		} else if ret == RetError {
+			// Pattern must be a prefix of errorCode.
+			pattern := tcl.ParseArgs(handlers[i+1])
+			code := tcl.ParseArgs(errorCode)
+			matched = len(pattern) <= len(code)
+			for j := 0; matched && j < len(pattern); j++ {
+				matched = pattern[j] == code[j]
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		// Script of - uses script of next handler.
+		script := i + 3
+		for script < len(handlers) && handlers[script] == "-" {
+			script += 4
+		}
+		if script >= len(handlers) {
+			return RetError, "last non-finally clause must not have a body of \"-\""
+		}
+
+		if strings.TrimSpace(handlers[i+2]) != "" {
+			vars := tcl.ParseArgs(handlers[i+2])
+			tcl.setVar(vars[0], result)
+			if len(vars) > 1 {
+				tcl.setVar(vars[1], tcl.returnOptions(ret))
+			}
+		}
+		ret = tcl.eval(handlers[script], parserOptions{})
+		return ret, tcl.result
+	}
+	return ret, result
+}
+
 // Set command set name ?value.
 func cmdSet(tcl *Tcl, args []string) int {
 	if len(args) < 1 || len(args) > 3 {
@@ -114,7 +277,7 @@ func cmdSet(tcl *Tcl, args []string) int {
 	}
 	name := args[1]
 	if len(args) > 2 {
-		tcl.SetVarValue(name, args[2])
+		return tcl.SetResult(tcl.setVar(name, args[2]))
 	}
 	ret, result := tcl.GetVarValue(name)
 	return tcl.SetResult(ret, result)
@@ -122,7 +285,14 @@ func cmdSet(tcl *Tcl, args []string) int {
 
 // Unset a list of variables.
 func cmdUnSet(tcl *Tcl, args []string) int {
-	for i := 1; i < len(args); i++ {
+	i := 1
+	if i < len(args) && args[i] == "-nocomplain" {
+		i++
+	}
+	if i < len(args) && args[i] == "--" {
+		i++
+	}
+	for ; i < len(args); i++ {
 		tcl.UnSetVar(args[i])
 	}
 	return RetOk
@@ -148,31 +318,46 @@ func cmdSubst(tcl *Tcl, args []string) int {
 
 // Print a string to standard output.
 func cmdPuts(tcl *Tcl, args []string) int {
-	text := args[1]
-	fmt.Println(text)
+	if len(args) != 2 {
+		return tcl.SetResult(RetError, "puts string")
+	}
+	fmt.Fprintln(tcl.stdout, args[1])
 	return tcl.SetResult(RetOk, "")
 }
 
 // Run a user process.
-func userProc(tcl *Tcl, args []string, params string, body string) int {
+func userProc(tcl *Tcl, args []string, ns *tclNamespace, params string, body string) int {
 	newenv := tcl.newEnv()
+	newenv.ns = ns
 	// Current argument number.
 	argNum := 1
 
 	argList := tcl.ParseArgs(params)
-	if len(argList) > 0 {
-		for i, name := range argList {
-			if name == "" {
-				break
-			}
-			if name == "args" && i == len(argList) {
-				tcl.setVarNewEnv(newenv, "args", strings.Join(args[argNum:], " "), true)
-				break
-			}
-			tcl.setVarNewEnv(newenv, name, args[argNum], true)
+	for i, param := range argList {
+		if param == "" {
+			break
+		}
+		if param == "args" && i == len(argList)-1 {
+			tcl.setVarNewEnv(newenv, "args", escapeList(args[argNum:]), true)
+			argNum = len(args)
+			break
+		}
+
+		// Parameter may be {name default}.
+		field := tcl.ParseArgs(param)
+		switch {
+		case argNum < len(args):
+			tcl.setVarNewEnv(newenv, field[0], args[argNum], true)
 			argNum++
+		case len(field) == 2:
+			tcl.setVarNewEnv(newenv, field[0], field[1], true)
+		default:
+			return tcl.SetResult(RetError, tcl.procUsage(args[0], argList))
 		}
 	}
+	if argNum < len(args) {
+		return tcl.SetResult(RetError, tcl.procUsage(args[0], argList))
+	}
 
 	newenv.args = strings.Join(args, " ")
 	// Switch to new environment and evaluate body of function.
@@ -180,19 +365,53 @@ func userProc(tcl *Tcl, args []string, params string, body string) int {
 	ret := tcl.eval(body, parserOptions{})
 	tcl.popEnv()
 	if ret == RetReturn {
-		ret = RetOk
+		ret = tcl.procReturn()
 	}
 	return ret
 }
 
+// Return wrong number of arguments message for procedure.
+func (tcl *Tcl) procUsage(name string, argList []string) string {
+	usage := "wrong # args: should be \"" + name
+	for i, param := range argList {
+		if param == "" {
+			break
+		}
+		switch field := tcl.ParseArgs(param); {
+		case param == "args" && i == len(argList)-1:
+			usage += " ?arg ...?"
+		case len(field) == 2:
+			usage += " ?" + field[0] + "?"
+		default:
+			usage += " " + param
+		}
+	}
+	return usage + "\""
+}
+
 // Create a user procedure.
 func cmdProc(tcl *Tcl, args []string) int {
 	if len(args) != 4 {
 		return tcl.SetResult(RetError, "proc args body")
 	}
 	name := args[1]
+	ns := tcl.env.ns
+
+	// Qualified names create the command in the named namespace.
+	if pos := strings.LastIndex(name, "::"); pos >= 0 {
+		nsName := name[:pos]
+		if nsName == "" {
+			nsName = "::"
+		}
+		ns = tcl.findNamespace(nsName, true)
+		name = name[pos+2:]
+	}
+	name = ns.cmdKey(name)
+	if cmd := tcl.cmds[name]; cmd != nil {
+		tcl.cmdDeleted(args[1], cmd)
+	}
 	tcl.cmds[name] = &tclCmd{
-		fn:   func(t *Tcl, arg []string) int { return userProc(t, arg, args[2], args[3]) },
+		fn:   func(t *Tcl, arg []string) int { return userProc(t, arg, ns, args[2], args[3]) },
 		proc: true,
 		args: args[2],
 		body: args[3],
@@ -200,6 +419,30 @@ func cmdProc(tcl *Tcl, args []string) int {
 	return tcl.SetResult(RetOk, "")
 }
 
+// Apply an anonymous procedure to arguments.
+func cmdApply(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "apply lambdaExpr ?arg ...")
+	}
+	lambda := tcl.ParseArgs(args[1])
+	if len(lambda) < 2 || len(lambda) > 3 {
+		return tcl.SetResult(RetError, "can't interpret \""+args[1]+"\" as a lambda expression")
+	}
+
+	// Namespace of lambda is relative to global namespace.
+	ns := tcl.global
+	if len(lambda) == 3 {
+		ns = tcl.findNamespace("::"+strings.TrimPrefix(lambda[2], "::"), true)
+	}
+	cmd := &tclCmd{
+		fn:   func(t *Tcl, arg []string) int { return userProc(t, arg, ns, lambda[0], lambda[1]) },
+		proc: true,
+		args: lambda[0],
+		body: lambda[1],
+	}
+	return cmd.fn(tcl, args[1:])
+}
+
 // Create link to variable in current environment.
 func cmdUpVar(tcl *Tcl, args []string) int {
 	if len(args) < 3 {
@@ -327,27 +570,50 @@ func cmdVariable(tcl *Tcl, args []string) int {
 		return tcl.SetResult(RetError, "variable name ?value")
 	}
 
-	// Get a variable at a environment.
+	// Create variable in current namespace and link it to current environment.
+	ns := tcl.env.ns
 	for v := 1; v < len(args); v += 2 {
+		variable, ok := ns.vars[args[v]]
+		if !ok {
+			variable = &tclVar{}
+			ns.vars[args[v]] = variable
+		}
+		tcl.env.vars[args[v]] = variable
 		if (v + 1) < len(args) {
-			tcl.SetVarValue(args[v], args[v+1])
+			variable.value = args[v+1]
+			tcl.varWritten(variable)
 		}
 	}
 	return tcl.SetResult(RetOk, "")
 }
 
+// Run delete traces of command and let it clean up after it has been deleted.
+func (tcl *Tcl) cmdDeleted(name string, cmd *tclCmd) {
+	tcl.runTraces(cmd.traces, "delete", name, "")
+	if cmd.deleted != nil {
+		cmd.deleted(tcl)
+	}
+}
+
 // Rename a procedure.
 func cmdRename(tcl *Tcl, args []string) int {
 	if len(args) < 2 || len(args) > 3 {
 		return tcl.SetResult(RetError, "rename OldName ?newName")
 	}
 	cmd, ok := tcl.cmds[args[1]]
-	if !ok {
+	if !ok || cmd == nil {
 		return tcl.SetResult(RetError, "command "+args[1]+" not found")
 	}
 	tcl.cmds[args[1]] = nil
-	if len(args) == 3 {
-		tcl.cmds[args[2]] = cmd
+
+	// Renaming to empty name deletes command.
+	if len(args) == 2 || args[2] == "" {
+		tcl.cmdDeleted(args[1], cmd)
+		return tcl.SetResult(RetOk, "")
+	}
+	tcl.cmds[args[2]] = cmd
+	if ret, msg := tcl.runTraces(cmd.traces, "rename", args[1], args[2]); ret != RetOk {
+		return tcl.SetResult(ret, msg)
 	}
 	return tcl.SetResult(RetOk, "")
 }
@@ -416,18 +682,95 @@ func cmdExit(tcl *Tcl, args []string) int {
 	return tcl.SetResult(RetExit, args[1])
 }
 
-// Handle simple control flow commands.
-func cmdReturn(tcl *Tcl, args []string) int {
-	switch len(args) {
-	case 1:
-		return tcl.SetResult(RetReturn, "")
+// Options of return command, used when procedure returns.
+type returnState struct {
+	code      int    // Completion code of procedure.
+	level     int    // Number of procedure levels to return through.
+	errorCode string // ErrorCode if code is error.
+	errorInfo string // Start of errorInfo if code is error.
+}
 
-	case 2:
-		return tcl.SetResult(RetReturn, args[1])
+// Return from procedure, return ?-code code ?-level level ?-errorcode list
+// ?-errorinfo info ?value.
+func cmdReturn(tcl *Tcl, args []string) int {
+	state := returnState{code: RetOk, level: 1}
+	i := 1
+	for ; i+1 < len(args) && strings.HasPrefix(args[i], "-"); i += 2 {
+		value := args[i+1]
+		switch args[i] {
+		case "-code":
+			code, ok := tryCodes[value]
+			if !ok {
+				code, ok = formatInteger(value)
+			}
+			if !ok {
+				return tcl.SetResult(RetError, "bad completion code \""+value+"\": must be ok, error, return, break, continue, or an integer")
+			}
+			state.code = code
+		case "-level":
+			level, ok := formatInteger(value)
+			if !ok || level < 0 {
+				return tcl.SetResult(RetError, "bad -level value: expected non-negative integer but got \""+value+"\"")
+			}
+			state.level = level
+		case "-errorcode":
+			state.errorCode = value
+		case "-errorinfo":
+			state.errorInfo = value
+		}
+	}
 
+	result := ""
+	switch len(args) - i {
+	case 0:
+	case 1:
+		result = args[i]
 	default:
 		return tcl.SetResult(RetError, "wrong number of arguments to return")
 	}
+
+	// Level 0 completes return command itself with code.
+	if state.level == 0 {
+		tcl.returning = returnState{}
+		tcl.setErrorState(state)
+		return tcl.SetResult(state.code, result)
+	}
+	tcl.returning = state
+	return tcl.SetResult(RetReturn, result)
+}
+
+// Complete return from a procedure, returns code procedure completes with.
+func (tcl *Tcl) procReturn() int {
+	state := tcl.returning
+	if state.level > 1 {
+		tcl.returning.level--
+		return RetReturn
+	}
+	tcl.returning = returnState{}
+	tcl.setErrorState(state)
+	return state.code
+}
+
+// Complete return that reached top level, any levels left are discarded.
+func (tcl *Tcl) topReturn() int {
+	state := tcl.returning
+	tcl.returning = returnState{}
+	tcl.setErrorState(state)
+	return state.code
+}
+
+// Set errorCode and errorInfo given by return options, if code is error.
+func (tcl *Tcl) setErrorState(state returnState) {
+	if state.code != RetError {
+		return
+	}
+	if state.errorCode != "" {
+		tcl.SetErrorCode(state.errorCode)
+	}
+	if state.errorInfo != "" {
+		tcl.setVar("::errorInfo", state.errorInfo)
+		tcl.inError = true
+	}
 }
 
 // Handle while {cond} {body}.
@@ -617,6 +960,50 @@ func stringCmp(tcl *Tcl, args []string) int {
 	return tcl.SetResult(RetOk, ret)
 }
 
+// Raise base to power exp by squaring, returns false if result overflows.
+// Negative powers are only non-zero for 1 and -1.
+func intPower(base int, exp int) (int, bool) {
+	if exp < 0 {
+		switch {
+		case base == 1:
+			return 1, true
+		case base == -1 && exp%2 != 0:
+			return -1, true
+		case base == -1:
+			return 1, true
+		}
+		return 0, true
+	}
+	result := 1
+	for exp > 0 {
+		var ok bool
+		if exp&1 != 0 {
+			if result, ok = mulInt(result, base); !ok {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		if exp > 0 {
+			if base, ok = mulInt(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// Multiply two integers, returns false if result overflows.
+func mulInt(a int, b int) (int, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) {
+		return 0, false
+	}
+	return c, true
+}
+
 // Compute binary operation.
 func binaryOp(tcl *Tcl, opr string, aval int, bval int) int {
 	switch opr {
@@ -627,7 +1014,16 @@ func binaryOp(tcl *Tcl, opr string, aval int, bval int) int {
 	case "*":
 		aval *= bval
 	case "/":
+		if bval == 0 {
+			return tcl.SetResult(RetError, "divide by zero")
+		}
 		aval /= bval
+	case "**":
+		result, ok := intPower(aval, bval)
+		if !ok {
+			return tcl.SetResult(RetError, "integer value too large to represent")
+		}
+		aval = result
 	case "and":
 		aval &= bval
 	case "or":
@@ -687,6 +1083,146 @@ func binaryOp(tcl *Tcl, opr string, aval int, bval int) int {
 	return tcl.SetResult(RetOk, ConvertNumberToString(aval, 10))
 }
 
+// Largest number of bits in result of ** on big integers.
+const maxPowerBits = 1 << 24
+
+// Compute binary operation on arbitrary precision integers.
+func bigBinaryOp(tcl *Tcl, opr string, aval *big.Int, bval *big.Int) int {
+	result := new(big.Int)
+	cmp := aval.Cmp(bval)
+	switch opr {
+	case "+":
+		result.Add(aval, bval)
+	case "-":
+		result.Sub(aval, bval)
+	case "*":
+		result.Mul(aval, bval)
+	case "/":
+		if bval.Sign() == 0 {
+			return tcl.SetResult(RetError, "divide by zero")
+		}
+		result.Quo(aval, bval)
+	case "**":
+		// Negative powers are only non-zero for 1 and -1, depending on parity.
+		if bval.Sign() < 0 {
+			if !aval.IsInt64() {
+				return tcl.SetResult(RetOk, "0")
+			}
+			return binaryOp(tcl, opr, int(aval.Int64()), -2+int(bval.Bit(0)))
+		}
+		// Limit size of result, powers of -1, 0 and 1 are always small.
+		if aval.CmpAbs(big.NewInt(1)) > 0 {
+			bits := big.NewInt(int64(aval.BitLen() - 1))
+			if bits.Mul(bits, bval).Cmp(big.NewInt(maxPowerBits)) > 0 {
+				return tcl.SetResult(RetError, "exponent too large")
+			}
+		}
+		result.Exp(aval, bval, nil)
+	case "and":
+		result.And(aval, bval)
+	case "or":
+		result.Or(aval, bval)
+	case "xor":
+		result.Xor(aval, bval)
+	case "max":
+		result.Set(aval)
+		if cmp < 0 {
+			result.Set(bval)
+		}
+	case "min":
+		result.Set(aval)
+		if cmp > 0 {
+			result.Set(bval)
+		}
+	case ">", ">=", "<", "<=", "==", "!=":
+		return binaryOp(tcl, opr, cmp, 0)
+	default:
+		return tcl.SetResult(RetError, "invalid operator")
+	}
+	return tcl.SetResult(RetOk, result.String())
+}
+
+// Compute binary operation on floating point numbers.
+func floatBinaryOp(tcl *Tcl, opr string, aval float64, bval float64) int {
+	switch opr {
+	case "+":
+		aval += bval
+	case "-":
+		aval -= bval
+	case "*":
+		aval *= bval
+	case "/":
+		if bval == 0 {
+			return tcl.SetResult(RetError, "divide by zero")
+		}
+		aval /= bval
+	case "**":
+		aval = math.Pow(aval, bval)
+	case "max":
+		aval = math.Max(aval, bval)
+	case "min":
+		aval = math.Min(aval, bval)
+	case ">", ">=", "<", "<=", "==", "!=":
+		cmp := 0
+		if aval < bval {
+			cmp = -1
+		} else if aval > bval {
+			cmp = 1
+		}
+		return binaryOp(tcl, opr, cmp, 0)
+	case "and", "or", "xor":
+		return tcl.SetResult(RetError, "can't use floating-point value as operand of "+opr)
+	default:
+		return tcl.SetResult(RetError, "invalid operator")
+	}
+	return tcl.SetResult(RetOk, ConvertFloatToString(aval))
+}
+
+// Compute unary operation on floating point number.
+func floatUnaryOp(tcl *Tcl, opr string, val float64) int {
+	switch opr {
+	case "-", "neg":
+		val = -val
+	case "abs":
+		val = math.Abs(val)
+	case "not":
+		if val == 0 {
+			return tcl.SetResult(RetOk, "1")
+		}
+		return tcl.SetResult(RetOk, "0")
+	case "bool":
+		if val != 0 {
+			return tcl.SetResult(RetOk, "1")
+		}
+		return tcl.SetResult(RetOk, "0")
+	case "+", "":
+	default:
+		return tcl.SetResult(RetError, "invalid operator")
+	}
+	return tcl.SetResult(RetOk, ConvertFloatToString(val))
+}
+
+// Convert number in str to arbitrary precision integer.
+func parseBigInt(str string) (*big.Int, bool) {
+	str = strings.TrimSpace(str)
+	neg := strings.HasPrefix(str, "-")
+	str = strings.TrimLeft(str, "+-")
+	base := 10
+	switch {
+	case strings.HasPrefix(str, "0x"):
+		base = 16
+		str = str[2:]
+	case len(str) > 1 && str[0] == '0':
+		base = 8
+		str = str[1:]
+	}
+	value, ok := new(big.Int).SetString(str, base)
+	if ok && neg {
+		value.Neg(value)
+	}
+	return value, ok
+}
+
 // Handle expr command.
 func cmdMath(tcl *Tcl, args []string) int {
 	// Join all arguments amd scan them ourselves.
@@ -702,6 +1238,12 @@ func cmdMath(tcl *Tcl, args []string) int {
 
 	// Try to convert first item to number.
 	aval, pos, binary := ConvertStringToNumber(str, 10, 0)
+	afloat, fpos, aIsFloat := ConvertStringToFloat(str, 0)
+	if aIsFloat {
+		pos = fpos
+		binary = true
+	}
+	aEnd := pos
 	bval := 0
 
 	if !binary && len(args) == 4 && relationOprs[args[2]] {
@@ -729,6 +1271,9 @@ func cmdMath(tcl *Tcl, args []string) int {
 
 	// If no operators, and passed end of string, return error.
 	if opr == "" {
+		if aIsFloat {
+			return tcl.SetResult(RetOk, ConvertFloatToString(afloat))
+		}
 		if binary {
 			return tcl.SetResult(RetOk, ConvertNumberToString(aval, 10))
 		}
@@ -736,7 +1281,12 @@ func cmdMath(tcl *Tcl, args []string) int {
 	}
 
 	// Convert 2nd or 3rd as number.
-	v, _, ok := ConvertStringToNumber(tcl.result, 10, pos)
+	v, bEnd, ok := ConvertStringToNumber(tcl.result, 10, pos)
+	bfloat, fpos, bIsFloat := ConvertStringToFloat(tcl.result, pos)
+	if bIsFloat {
+		bEnd = fpos
+		ok = true
+	}
 	if !ok {
 		if len(args) == 4 && relationOprs[args[2]] {
 			return stringCmp(tcl, args)
@@ -746,6 +1296,28 @@ func cmdMath(tcl *Tcl, args []string) int {
 	}
 	bval = v
 
+	// Either operand floating point makes result floating point.
+	if aIsFloat || bIsFloat {
+		if !aIsFloat {
+			afloat = float64(aval)
+		}
+		if !bIsFloat {
+			bfloat = float64(bval)
+		}
+		if binary {
+			return floatBinaryOp(tcl, opr, afloat, bfloat)
+		}
+		return floatUnaryOp(tcl, opr, bfloat)
+	}
+
+	if binary && tcl.bigInt {
+		// Reconvert numbers, they may be too large for int.
+		abig, aok := parseBigInt(str[:aEnd])
+		bbig, bok := parseBigInt(str[pos:bEnd])
+		if aok && bok {
+			return bigBinaryOp(tcl, opr, abig, bbig)
+		}
+	}
 	if binary {
 		return binaryOp(tcl, opr, aval, bval)
 	}
@@ -803,9 +1375,12 @@ func cmdIncr(tcl *Tcl, args []string) int {
 
 // decr var ?amount.
 func cmdDecr(tcl *Tcl, args []string) int {
+	if len(args) != 2 && len(args) != 3 {
+		return tcl.SetResult(RetError, "decr varName ?decrement")
+	}
 	r, value := tcl.GetVarValue(args[1])
 	if r != RetOk {
-		return r
+		return tcl.SetResult(r, value)
 	}
 	aval, _, ok := ConvertStringToNumber(value, 10, 0)
 	if !ok {
@@ -820,9 +1395,9 @@ func cmdDecr(tcl *Tcl, args []string) int {
 			return RetError
 		}
 	}
-	result := ConvertNumberToString(aval+decr, 10)
+	result := ConvertNumberToString(aval-decr, 10)
 	tcl.SetVarValue(args[1], result)
-	return RetOk
+	return tcl.SetResult(RetOk, result)
 }
 
 // Concatenate all arguments to one string.
@@ -854,19 +1429,30 @@ func cmdEval(tcl *Tcl, args []string) int {
 
 // Append arguments to variable. append var ?args.
 func cmdAppend(tcl *Tcl, args []string) int {
-	if len(args) < 1 {
+	if len(args) < 2 {
 		tcl.result = "append name ?value"
 		return RetError
 	}
 	name := args[1]
-	str := strings.Join(args[2:], "")
 	ret, result := tcl.GetVarValue(name)
-	if ret == RetOk {
-		str = result + str
+	if ret != RetOk {
+		result = ""
+	}
+
+	// Build new value in one allocation.
+	size := len(result)
+	for _, arg := range args[2:] {
+		size += len(arg)
+	}
+	var str strings.Builder
+	str.Grow(size)
+	str.WriteString(result)
+	for _, arg := range args[2:] {
+		str.WriteString(arg)
 	}
 
-	tcl.SetVarValue(name, str)
-	return tcl.SetResult(RetOk, str)
+	tcl.SetVarValue(name, str.String())
+	return tcl.SetResult(RetOk, str.String())
 }
 
 // Compare two arguments as strings.
@@ -0,0 +1,486 @@
+/*
+ * TCL  binary command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var binaryMap = map[string]func(*Tcl, []string) int{
+	"format": binaryFormat, // formatString ?arg ...
+	"scan":   binaryScan,   // value formatString ?varName ...
+}
+
+// Field counts that are not numbers.
+const (
+	binaryNoCount = -1 // No count given.
+	binaryAll     = -2 // Count given as *.
+)
+
+// All valid field types.
+const binaryTypes = "aAbBhHcsSiIwWfdxX@"
+
+// Size in bytes of numeric field types.
+var binarySizes = map[byte]int{
+	'c': 1,
+	's': 2,
+	'S': 2,
+	'i': 4,
+	'I': 4,
+	'w': 8,
+	'W': 8,
+	'f': 4,
+	'd': 8,
+}
+
+// Process binary command.
+func cmdBinary(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "binary subcommand ?arg ...")
+	}
+	fn, ok := binaryMap[args[1]]
+	if !ok {
+		return tcl.SetResult(RetError, "binary unknown subcommand "+args[1])
+	}
+	return fn(tcl, args)
+}
+
+// Parse next field of format string, returns type, count, unsigned flag and
+// position after the field.
+func binaryField(format string, pos int) (byte, int, bool, int) {
+	typ := format[pos]
+	pos++
+	unsigned := false
+	if pos < len(format) && format[pos] == 'u' {
+		unsigned = true
+		pos++
+	}
+	if pos < len(format) && format[pos] == '*' {
+		return typ, binaryAll, unsigned, pos + 1
+	}
+	start := pos
+	for pos < len(format) && isDigit(format[pos], 10) {
+		pos++
+	}
+	if start == pos {
+		return typ, binaryNoCount, unsigned, pos
+	}
+	count, _ := strconv.Atoi(format[start:pos])
+	return typ, count, unsigned, pos
+}
+
+// Encode value as numeric field type into buf, returns error message.
+func binaryPut(buf []byte, typ byte, value string) string {
+	if typ == 'f' || typ == 'd' {
+		num, ok := formatFloat(value)
+		if !ok {
+			return "expected floating-point number but got \"" + value + "\""
+		}
+		if typ == 'f' {
+			binary.LittleEndian.PutUint32(buf, math.Float32bits(float32(num)))
+		} else {
+			binary.LittleEndian.PutUint64(buf, math.Float64bits(num))
+		}
+		return ""
+	}
+
+	num, ok := formatInteger(value)
+	if !ok {
+		return "expected integer but got \"" + value + "\""
+	}
+	switch typ {
+	case 'c':
+		buf[0] = byte(num)
+	case 's':
+		binary.LittleEndian.PutUint16(buf, uint16(num))
+	case 'S':
+		binary.BigEndian.PutUint16(buf, uint16(num))
+	case 'i':
+		binary.LittleEndian.PutUint32(buf, uint32(num))
+	case 'I':
+		binary.BigEndian.PutUint32(buf, uint32(num))
+	case 'w':
+		binary.LittleEndian.PutUint64(buf, uint64(num))
+	case 'W':
+		binary.BigEndian.PutUint64(buf, uint64(num))
+	}
+	return ""
+}
+
+// Decode numeric field type from data.
+func binaryGet(data []byte, typ byte, unsigned bool) string {
+	var num uint64
+	var signed int64
+	switch typ {
+	case 'c':
+		num, signed = uint64(data[0]), int64(int8(data[0]))
+	case 's', 'S':
+		v := binary.LittleEndian.Uint16(data)
+		if typ == 'S' {
+			v = binary.BigEndian.Uint16(data)
+		}
+		num, signed = uint64(v), int64(int16(v))
+	case 'i', 'I':
+		v := binary.LittleEndian.Uint32(data)
+		if typ == 'I' {
+			v = binary.BigEndian.Uint32(data)
+		}
+		num, signed = uint64(v), int64(int32(v))
+	case 'w', 'W':
+		num = binary.LittleEndian.Uint64(data)
+		if typ == 'W' {
+			num = binary.BigEndian.Uint64(data)
+		}
+		signed = int64(num)
+	case 'f':
+		return ConvertFloatToString(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))))
+	case 'd':
+		return ConvertFloatToString(math.Float64frombits(binary.LittleEndian.Uint64(data)))
+	}
+	if unsigned {
+		return strconv.FormatUint(num, 10)
+	}
+	return strconv.FormatInt(signed, 10)
+}
+
+// Build binary string from values according to format string.
+func binaryFormat(tcl *Tcl, args []string) int {
+	if len(args) < 3 {
+		return tcl.SetResult(RetError, "binary format formatString ?arg ...")
+	}
+	format := args[2]
+	arg := 3
+	out := []byte{}
+	cursor := 0
+
+	// Store bytes at cursor, extending output if needed.
+	write := func(data []byte) {
+		end := cursor + len(data)
+		if end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[cursor:], data)
+		cursor = end
+	}
+
+	for pos := 0; pos < len(format); {
+		if isSpace(format[pos]) {
+			pos++
+			continue
+		}
+		typ, count, _, next := binaryField(format, pos)
+		pos = next
+
+		if !strings.ContainsRune(binaryTypes, rune(typ)) {
+			return tcl.SetResult(RetError, "bad field specifier \""+string(typ)+"\"")
+		}
+
+		// Get argument for types that take a value.
+		value := ""
+		if !strings.ContainsRune("xX@", rune(typ)) {
+			if arg >= len(args) {
+				return tcl.SetResult(RetError, "not enough arguments for all format specifiers")
+			}
+			value = args[arg]
+			arg++
+		}
+
+		switch typ {
+		case 'a', 'A':
+			switch count {
+			case binaryAll:
+				count = len(value)
+			case binaryNoCount:
+				count = 1
+			}
+			pad := byte(0)
+			if typ == 'A' {
+				pad = ' '
+			}
+			data := make([]byte, count)
+			for i := range data {
+				data[i] = pad
+			}
+			copy(data, value)
+			write(data)
+
+		case 'b', 'B', 'h', 'H':
+			switch count {
+			case binaryAll:
+				count = len(value)
+			case binaryNoCount:
+				count = 1
+			}
+			data, err := binaryDigits(typ, value, count)
+			if err != "" {
+				return tcl.SetResult(RetError, err)
+			}
+			write(data)
+
+		case 'c', 's', 'S', 'i', 'I', 'w', 'W', 'f', 'd':
+			values := []string{value}
+			if count != binaryNoCount {
+				values = []string{}
+				if strings.TrimSpace(value) != "" {
+					values = tcl.ParseArgs(value)
+				}
+				if count == binaryAll {
+					count = len(values)
+				}
+				if count > len(values) {
+					return tcl.SetResult(RetError, "number of elements in list does not match count")
+				}
+				values = values[:count]
+			}
+			size := binarySizes[typ]
+			data := make([]byte, size*len(values))
+			for i, v := range values {
+				if err := binaryPut(data[i*size:], typ, v); err != "" {
+					return tcl.SetResult(RetError, err)
+				}
+			}
+			write(data)
+
+		case 'x':
+			switch count {
+			case binaryAll:
+				return tcl.SetResult(RetError, "cannot use \"*\" in format string with \"x\"")
+			case binaryNoCount:
+				count = 1
+			}
+			write(make([]byte, count))
+
+		case 'X':
+			if count == binaryNoCount {
+				count = 1
+			}
+			if count == binaryAll || count > cursor {
+				count = cursor
+			}
+			cursor -= count
+
+		case '@':
+			switch count {
+			case binaryAll:
+				cursor = len(out)
+			case binaryNoCount:
+				return tcl.SetResult(RetError, "missing count for \"@\" field specifier")
+			default:
+				if count > len(out) {
+					out = append(out, make([]byte, count-len(out))...)
+				}
+				cursor = count
+			}
+
+		}
+	}
+
+	if arg != len(args) {
+		return tcl.SetResult(RetError, "too many arguments for all format specifiers")
+	}
+	return tcl.SetResult(RetOk, string(out))
+}
+
+// Pack count binary or hex digits of value into bytes. Bits fill each
+// byte from low to high for b and h, and high to low for B and H.
+func binaryDigits(typ byte, value string, count int) ([]byte, string) {
+	bits := 1
+	kind := "binary"
+	if typ == 'h' || typ == 'H' {
+		bits = 4
+		kind = "hexadecimal"
+	}
+	perByte := 8 / bits
+	data := make([]byte, (count+perByte-1)/perByte)
+	for i := 0; i < count && i < len(value); i++ {
+		digit := strings.Index(hex, strings.ToLower(value[i:i+1]))
+		if digit < 0 || digit >= 1<<bits {
+			return nil, "expected " + kind + " string but got \"" + value + "\" instead"
+		}
+		shift := (i % perByte) * bits
+		if typ == 'B' || typ == 'H' {
+			shift = 8 - bits - shift
+		}
+		data[i/perByte] |= byte(digit << shift)
+	}
+	return data, ""
+}
+
+// Unpack count binary or hex digits from data.
+func binaryString(typ byte, data []byte, count int) string {
+	bits := 1
+	if typ == 'h' || typ == 'H' {
+		bits = 4
+	}
+	perByte := 8 / bits
+	var result strings.Builder
+	for i := range count {
+		shift := (i % perByte) * bits
+		if typ == 'B' || typ == 'H' {
+			shift = 8 - bits - shift
+		}
+		result.WriteByte(hex[(data[i/perByte]>>shift)&(1<<bits-1)])
+	}
+	return result.String()
+}
+
+// Extract values from binary string according to format string and store
+// them in variables. Returns number of variables set.
+func binaryScan(tcl *Tcl, args []string) int {
+	if len(args) < 4 {
+		return tcl.SetResult(RetError, "binary scan value formatString ?varName ...")
+	}
+	data := []byte(args[2])
+	format := args[3]
+	arg := 4
+	cursor := 0
+	set := 0
+
+outer:
+	for pos := 0; pos < len(format); {
+		if isSpace(format[pos]) {
+			pos++
+			continue
+		}
+		typ, count, unsigned, next := binaryField(format, pos)
+		pos = next
+
+		if !strings.ContainsRune(binaryTypes, rune(typ)) {
+			return tcl.SetResult(RetError, "bad field specifier \""+string(typ)+"\"")
+		}
+
+		// Get variable for types that give a value.
+		name := ""
+		if !strings.ContainsRune("xX@", rune(typ)) {
+			if arg >= len(args) {
+				return tcl.SetResult(RetError, "not enough arguments for all format specifiers")
+			}
+			name = args[arg]
+			arg++
+		}
+
+		remain := len(data) - cursor
+		value := ""
+		switch typ {
+		case 'a', 'A':
+			switch count {
+			case binaryAll:
+				count = remain
+			case binaryNoCount:
+				count = 1
+			}
+			if count > remain {
+				break outer
+			}
+			value = string(data[cursor : cursor+count])
+			if typ == 'A' {
+				value = strings.TrimRight(value, " \x00")
+			}
+			cursor += count
+
+		case 'b', 'B', 'h', 'H':
+			perByte := 8
+			if typ == 'h' || typ == 'H' {
+				perByte = 2
+			}
+			switch count {
+			case binaryAll:
+				count = remain * perByte
+			case binaryNoCount:
+				count = 1
+			}
+			size := (count + perByte - 1) / perByte
+			if size > remain {
+				break outer
+			}
+			value = binaryString(typ, data[cursor:], count)
+			cursor += size
+
+		case 'c', 's', 'S', 'i', 'I', 'w', 'W', 'f', 'd':
+			size := binarySizes[typ]
+			if count == binaryNoCount {
+				if size > remain {
+					break outer
+				}
+				value = binaryGet(data[cursor:], typ, unsigned)
+				cursor += size
+				break
+			}
+			if count == binaryAll {
+				count = remain / size
+			}
+			if count*size > remain {
+				break outer
+			}
+			values := make([]string, count)
+			for i := range values {
+				values[i] = binaryGet(data[cursor:], typ, unsigned)
+				cursor += size
+			}
+			value = strings.Join(values, " ")
+
+		case 'x':
+			if count == binaryNoCount {
+				count = 1
+			}
+			if count == binaryAll || count > remain {
+				count = remain
+			}
+			cursor += count
+
+		case 'X':
+			if count == binaryNoCount {
+				count = 1
+			}
+			if count == binaryAll || count > cursor {
+				count = cursor
+			}
+			cursor -= count
+
+		case '@':
+			switch count {
+			case binaryAll:
+				cursor = len(data)
+			case binaryNoCount:
+				return tcl.SetResult(RetError, "missing count for \"@\" field specifier")
+			default:
+				cursor = min(count, len(data))
+			}
+
+		}
+
+		if name != "" {
+			if ret, msg := tcl.setVar(name, value); ret != RetOk {
+				return tcl.SetResult(ret, msg)
+			}
+			set++
+		}
+	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(set, 10))
+}
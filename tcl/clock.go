@@ -0,0 +1,339 @@
+/*
+ * TCL  clock command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+var clockMap = map[string]func(*Tcl, []string) int{
+	"add":          clockAdd,    // timeVal ?count unit ... ?-gmt bool
+	"format":       clockFormat, // timeVal ?-format fmt ?-gmt bool
+	"microseconds": clockMicroseconds,
+	"milliseconds": clockMilliseconds,
+	"scan":         clockScan, // string ?-format fmt ?-base timeVal ?-gmt bool
+	"seconds":      clockSeconds,
+}
+
+// Default format of clock format.
+const clockDefaultFormat = "%a %b %d %H:%M:%S %Z %Y"
+
+// Go layouts for each strftime conversion.
+var clockLayouts = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'c': "Mon Jan _2 15:04:05 2006",
+	'd': "02",
+	'D': "01/02/06",
+	'e': "_2",
+	'h': "Jan",
+	'H': "15",
+	'I': "03",
+	'j': "002",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'R': "15:04",
+	'S': "05",
+	'T': "15:04:05",
+	'x': "01/02/06",
+	'X': "15:04:05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+}
+
+// Layouts tried by clock scan when no format is given.
+var clockScanLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"01/02/2006 15:04:05",
+	"01/02/2006",
+	"Mon Jan _2 15:04:05 MST 2006",
+	time.RFC1123Z,
+	time.RFC1123,
+	"15:04:05",
+	"15:04",
+}
+
+// Clock command.
+func cmdClock(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "clock subcommand ?arg ...")
+	}
+	fn, ok := clockMap[args[1]]
+	if !ok {
+		return tcl.SetResult(RetError, "clock unknown subcommand "+args[1])
+	}
+	return fn(tcl, args)
+}
+
+// Return current time in seconds.
+func clockSeconds(tcl *Tcl, args []string) int {
+	if len(args) != 2 {
+		return tcl.SetResult(RetError, "clock seconds")
+	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(int(time.Now().Unix()), 10))
+}
+
+// Return current time in milliseconds.
+func clockMilliseconds(tcl *Tcl, args []string) int {
+	if len(args) != 2 {
+		return tcl.SetResult(RetError, "clock milliseconds")
+	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(int(time.Now().UnixMilli()), 10))
+}
+
+// Return current time in microseconds.
+func clockMicroseconds(tcl *Tcl, args []string) int {
+	if len(args) != 2 {
+		return tcl.SetResult(RetError, "clock microseconds")
+	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(int(time.Now().UnixMicro()), 10))
+}
+
+// Options of clock subcommands.
+type clockOptions struct {
+	format string         // Format of time string.
+	base   int            // Time used for missing date.
+	loc    *time.Location // Time zone.
+}
+
+// Parse options from args, allowed lists which options may be given. Returns
+// error message if option is not valid.
+func parseClockOptions(args []string, allowed string) (clockOptions, string) {
+	opts := clockOptions{loc: time.Local, base: int(time.Now().Unix())}
+	for i := 0; i < len(args); i += 2 {
+		if !slices.Contains(strings.Split(allowed, ", "), args[i]) {
+			return opts, "bad option \"" + args[i] + "\", must be one of " + allowed
+		}
+		if i+1 >= len(args) {
+			return opts, "missing value for option " + args[i]
+		}
+		value := args[i+1]
+		switch args[i] {
+		case "-format":
+			opts.format = value
+		case "-base":
+			base, ok := formatInteger(value)
+			if !ok {
+				return opts, "expected integer but got \"" + value + "\""
+			}
+			opts.base = base
+		case "-gmt":
+			gmt, ok := truthValue[value]
+			if !ok {
+				return opts, "expected boolean value but got \"" + value + "\""
+			}
+			if gmt {
+				opts.loc = time.UTC
+			}
+		}
+	}
+	return opts, ""
+}
+
+// Format a time value.
+func clockFormat(tcl *Tcl, args []string) int {
+	if len(args) < 3 || len(args)%2 != 1 {
+		return tcl.SetResult(RetError, "clock format timeVal ?-format fmt ?-gmt bool")
+	}
+	seconds, ok := formatInteger(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "expected integer but got \""+args[2]+"\"")
+	}
+	opts, msg := parseClockOptions(args[3:], "-format, -gmt")
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+	if opts.format == "" {
+		opts.format = clockDefaultFormat
+	}
+	t := time.Unix(int64(seconds), 0).In(opts.loc)
+	return tcl.SetResult(RetOk, formatTime(t, opts.format))
+}
+
+// Convert time to string using strftime style format.
+func formatTime(t time.Time, format string) string {
+	var result strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			result.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case '%':
+			result.WriteByte('%')
+		case 'n':
+			result.WriteByte('\n')
+		case 't':
+			result.WriteByte('\t')
+		case 's':
+			result.WriteString(ConvertNumberToString(int(t.Unix()), 10))
+		case 'u':
+			day := int(t.Weekday())
+			if day == 0 {
+				day = 7
+			}
+			result.WriteString(ConvertNumberToString(day, 10))
+		case 'w':
+			result.WriteString(ConvertNumberToString(int(t.Weekday()), 10))
+		default:
+			layout, ok := clockLayouts[format[i]]
+			if !ok {
+				result.WriteByte('%')
+				result.WriteByte(format[i])
+				continue
+			}
+			result.WriteString(t.Format(layout))
+		}
+	}
+	return result.String()
+}
+
+// Convert strftime style format to Go layout.
+func clockLayout(format string) string {
+	var layout strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			layout.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case '%':
+			layout.WriteByte('%')
+		case 'n':
+			layout.WriteByte('\n')
+		case 't':
+			layout.WriteByte('\t')
+		default:
+			layout.WriteString(clockLayouts[format[i]])
+		}
+	}
+	return layout.String()
+}
+
+// Convert string to time value.
+func clockScan(tcl *Tcl, args []string) int {
+	if len(args) < 3 || len(args)%2 != 1 {
+		return tcl.SetResult(RetError, "clock scan string ?-format fmt ?-base timeVal ?-gmt bool")
+	}
+	opts, msg := parseClockOptions(args[3:], "-format, -base, -gmt")
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+	str := strings.TrimSpace(args[2])
+
+	// Seconds since epoch are accepted with %s format.
+	if opts.format == "%s" {
+		seconds, ok := formatInteger(str)
+		if !ok {
+			return tcl.SetResult(RetError, "unable to convert input string: \""+str+"\"")
+		}
+		return tcl.SetResult(RetOk, ConvertNumberToString(seconds, 10))
+	}
+
+	layouts := clockScanLayouts
+	if opts.format != "" {
+		layouts = []string{clockLayout(opts.format)}
+	}
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout, str, opts.loc)
+		if err != nil {
+			continue
+		}
+
+		// Times without a date are on the day of base.
+		if t.Year() == 0 {
+			base := time.Unix(int64(opts.base), 0).In(opts.loc)
+			t = time.Date(base.Year(), base.Month(), base.Day(), t.Hour(), t.Minute(), t.Second(), 0, opts.loc)
+		}
+		return tcl.SetResult(RetOk, ConvertNumberToString(int(t.Unix()), 10))
+	}
+	return tcl.SetResult(RetError, "unable to convert input string: \""+str+"\"")
+}
+
+// Add counts of units to a time value.
+func clockAdd(tcl *Tcl, args []string) int {
+	usage := "clock add timeVal ?count unit ... ?-gmt bool"
+	if len(args) < 3 {
+		return tcl.SetResult(RetError, usage)
+	}
+	seconds, ok := formatInteger(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "expected integer but got \""+args[2]+"\"")
+	}
+
+	// Options follow count unit pairs.
+	rest := args[3:]
+	opt := 0
+	for opt < len(rest) && !strings.HasPrefix(rest[opt], "-") {
+		opt += 2
+	}
+	if opt > len(rest) {
+		return tcl.SetResult(RetError, usage)
+	}
+	opts, msg := parseClockOptions(rest[opt:], "-gmt")
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+
+	t := time.Unix(int64(seconds), 0).In(opts.loc)
+	for i := 0; i < opt; i += 2 {
+		count, ok := formatInteger(rest[i])
+		if !ok {
+			return tcl.SetResult(RetError, "expected integer but got \""+rest[i]+"\"")
+		}
+		switch strings.TrimSuffix(rest[i+1], "s") {
+		case "second":
+			t = t.Add(time.Duration(count) * time.Second)
+		case "minute":
+			t = t.Add(time.Duration(count) * time.Minute)
+		case "hour":
+			t = t.Add(time.Duration(count) * time.Hour)
+		case "day":
+			t = t.AddDate(0, 0, count)
+		case "week":
+			t = t.AddDate(0, 0, 7*count)
+		case "month":
+			t = t.AddDate(0, count, 0)
+		case "year":
+			t = t.AddDate(count, 0, 0)
+		default:
+			return tcl.SetResult(RetError, "unknown unit \""+rest[i+1]+"\", must be seconds, minutes, hours, days, weeks, months or years")
+		}
+	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(int(t.Unix()), 10))
+}
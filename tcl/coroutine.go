@@ -0,0 +1,160 @@
+/*
+ * TCL  coroutine and yield commands.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+// Coroutine, runs its command in a goroutine that takes turns with caller.
+type coroutine struct {
+	name    string        // Command name of coroutine.
+	cmd     *tclCmd       // Command that resumes coroutine.
+	resume  chan string   // Values passed in when coroutine resumes.
+	yield   chan coResult // Values passed out when coroutine yields or ends.
+	env     *tclEnv       // Environment of suspended coroutine.
+	level   int           // Level of suspended coroutine.
+	running bool          // Coroutine is currently running.
+	deleted bool          // Command was deleted, coroutine is unwinding.
+}
+
+// Result passed back to caller of coroutine.
+type coResult struct {
+	ret   int    // Completion code.
+	value string // Result.
+	done  bool   // Command of coroutine has finished.
+}
+
+// Create coroutine name, running cmd until it yields.
+func cmdCoroutine(tcl *Tcl, args []string) int {
+	if len(args) < 3 {
+		return tcl.SetResult(RetError, "coroutine name cmd ?arg ...")
+	}
+	name := tcl.env.ns.cmdKey(args[1])
+	if _, ok := tcl.cmds[name]; ok {
+		return tcl.SetResult(RetError, "command \""+args[1]+"\" already exists")
+	}
+
+	// Coroutine starts at global level.
+	global := tcl.env
+	for global.parent != nil {
+		global = global.parent
+	}
+	co := &coroutine{
+		name:   name,
+		resume: make(chan string),
+		yield:  make(chan coResult),
+		env:    global,
+		level:  global.level,
+	}
+	co.cmd = &tclCmd{fn: co.call, deleted: co.delete}
+	tcl.cmds[name] = co.cmd
+
+	cmd := args[2:]
+	go func() {
+		<-co.resume
+		ret := tcl.doCommand(cmd)
+		if ret == RetReturn {
+			ret = RetOk
+		}
+		co.yield <- coResult{ret: ret, value: tcl.result, done: true}
+	}()
+	return co.transfer(tcl, "")
+}
+
+// Resume coroutine from its command, with optional value returned by yield.
+func (co *coroutine) call(tcl *Tcl, args []string) int {
+	if len(args) > 2 {
+		return tcl.SetResult(RetError, "wrong # args: should be \""+args[0]+" ?arg?\"")
+	}
+	value := ""
+	if len(args) == 2 {
+		value = args[1]
+	}
+	return co.transfer(tcl, value)
+}
+
+// Run coroutine until it yields or finishes, then restore caller.
+func (co *coroutine) transfer(tcl *Tcl, value string) int {
+	if co.running {
+		return tcl.SetResult(RetError, "coroutine \""+co.name+"\" is already running")
+	}
+	saveEnv, saveLevel, saveCo := tcl.env, tcl.level, tcl.coroutine
+	tcl.env, tcl.level, tcl.coroutine = co.env, co.level, co
+	co.running = true
+	co.resume <- value
+	res := <-co.yield
+
+	// Deleted coroutine is resumed until its command has unwound.
+	for co.deleted && !res.done {
+		co.resume <- ""
+		res.done = (<-co.yield).done
+	}
+	co.running = false
+	co.env, co.level = tcl.env, tcl.level
+	tcl.env, tcl.level, tcl.coroutine = saveEnv, saveLevel, saveCo
+
+	// Finished coroutines remove their command.
+	if res.done {
+		for key, cmd := range tcl.cmds {
+			if cmd == co.cmd {
+				delete(tcl.cmds, key)
+			}
+		}
+	}
+	return tcl.SetResult(res.ret, res.value)
+}
+
+// Unwind coroutine when its command is deleted. A running coroutine is
+// unwound when it next yields.
+func (co *coroutine) delete(tcl *Tcl) {
+	co.deleted = true
+	if co.running {
+		return
+	}
+	result := tcl.result
+	co.transfer(tcl, "")
+	tcl.result = result
+}
+
+// Suspend running coroutine, returning value to its caller.
+func cmdYield(tcl *Tcl, args []string) int {
+	if len(args) > 2 {
+		return tcl.SetResult(RetError, "yield ?value")
+	}
+	co := tcl.coroutine
+	if co == nil {
+		return tcl.SetResult(RetError, "yield can only be called in a coroutine")
+	}
+	value := ""
+	if len(args) == 2 {
+		value = args[1]
+	}
+	co.yield <- coResult{ret: RetOk, value: value}
+	value = <-co.resume
+
+	// Deleted coroutine unwinds with exit, which catch does not stop.
+	if co.deleted {
+		return tcl.SetResult(RetExit, "coroutine \""+co.name+"\" deleted")
+	}
+	return tcl.SetResult(RetOk, value)
+}
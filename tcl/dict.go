@@ -0,0 +1,603 @@
+/*
+ * TCL  dict command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"strings"
+)
+
+// Dictionary, keeps keys in order they were added.
+type tclDict struct {
+	keys   []string          // Keys in insertion order.
+	values map[string]string // Value for each key.
+}
+
+var dictMap = map[string]func(*Tcl, []string) int{
+	"append":  dictAppend,  // dictVarName key ?string ...
+	"create":  dictCreate,  // ?key value ...
+	"exists":  dictExists,  // dictionary key ?key ...
+	"filter":  dictFilter,  // dictionary filterType ?arg ...
+	"for":     dictFor,     // {keyVarName valueVarName} dictionary body
+	"get":     dictGet,     // ?-default value dictionary ?key ...
+	"getdef":  dictGetDef,  // dictionary ?key ... key default
+	"incr":    dictIncr,    // dictVarName key ?increment
+	"keys":    dictKeys,    // dictionary ?pattern
+	"lappend": dictLappend, // dictVarName key ?value ...
+	"merge":   dictMerge,   // ?dictionary ...
+	"remove":  dictRemove,  // dictionary ?key ...
+	"replace": dictReplace, // dictionary ?key value ...
+	"set":     dictSet,     // dictVarName key ?key ... value
+	"size":    dictSize,    // dictionary
+	"unset":   dictUnset,   // dictVarName key ?key ...
+	"update":  dictUpdate,  // dictVarName key varName ?key varName ... body
+	"values":  dictValues,  // dictionary ?pattern
+}
+
+// Convert a list into a dictionary.
+func (tcl *Tcl) parseDict(str string) (*tclDict, bool) {
+	dict := &tclDict{values: make(map[string]string)}
+	if str == "" {
+		return dict, true
+	}
+	list := tcl.ParseArgs(str)
+	if len(list)%2 != 0 {
+		return nil, false
+	}
+	for i := 0; i < len(list); i += 2 {
+		dict.set(list[i], list[i+1])
+	}
+	return dict, true
+}
+
+// Set key to value, new keys are added at end.
+func (dict *tclDict) set(key string, value string) {
+	if _, ok := dict.values[key]; !ok {
+		dict.keys = append(dict.keys, key)
+	}
+	dict.values[key] = value
+}
+
+// Remove key from dictionary.
+func (dict *tclDict) remove(key string) {
+	if _, ok := dict.values[key]; !ok {
+		return
+	}
+	delete(dict.values, key)
+	for i, k := range dict.keys {
+		if k == key {
+			dict.keys = append(dict.keys[:i], dict.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Convert dictionary back to a list.
+func (dict *tclDict) String() string {
+	str := ""
+	for _, key := range dict.keys {
+		str += " " + StringEscape(key) + " " + StringEscape(dict.values[key])
+	}
+	if str == "" {
+		return ""
+	}
+	return str[1:]
+}
+
+// Get dictionary held in variable, variables that do not exist are empty.
+func (tcl *Tcl) dictVar(name string) (*tclDict, bool) {
+	ret, str := tcl.GetVarValue(name)
+	if ret != RetOk {
+		str = ""
+	}
+	return tcl.parseDict(str)
+}
+
+// Store dictionary in variable and return it as result.
+func (tcl *Tcl) storeDict(name string, dict *tclDict) int {
+	str := dict.String()
+	if ret, msg := tcl.setVar(name, str); ret != RetOk {
+		return tcl.SetResult(ret, msg)
+	}
+	return tcl.SetResult(RetOk, str)
+}
+
+// Return names of keys matching pattern, all keys if pattern is empty.
+func (dict *tclDict) matchKeys(pattern string, useValues bool) []string {
+	result := []string{}
+	for _, key := range dict.keys {
+		item := key
+		if useValues {
+			item = dict.values[key]
+		}
+		if pattern == "" || globMatch(pattern, item) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// Dictionary command.
+func cmdDict(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "dict subcommand ?arg ...")
+	}
+	fn, ok := dictMap[args[1]]
+	if !ok {
+		return tcl.SetResult(RetError, "dict unknown subcommand "+args[1])
+	}
+	return fn(tcl, args)
+}
+
+// Append strings to value stored under key in dictionary variable.
+func dictAppend(tcl *Tcl, args []string) int {
+	if len(args) < 4 {
+		return tcl.SetResult(RetError, "dict append dictVarName key ?string ...")
+	}
+	dict, ok := tcl.dictVar(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+	dict.set(args[3], dict.values[args[3]]+strings.Join(args[4:], ""))
+	return tcl.storeDict(args[2], dict)
+}
+
+// Create a dictionary from key value pairs.
+func dictCreate(tcl *Tcl, args []string) int {
+	if len(args)%2 != 0 {
+		return tcl.SetResult(RetError, "dict create ?key value ...")
+	}
+	dict := &tclDict{values: make(map[string]string)}
+	for i := 2; i < len(args); i += 2 {
+		dict.set(args[i], args[i+1])
+	}
+	return tcl.SetResult(RetOk, dict.String())
+}
+
+// Return 1 if path of keys exists in nested dictionaries.
+func dictExists(tcl *Tcl, args []string) int {
+	if len(args) < 4 {
+		return tcl.SetResult(RetError, "dict exists dictionary key ?key ...")
+	}
+	dict, ok := tcl.parseDict(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+	for i, key := range args[3:] {
+		value, ok := dict.values[key]
+		if !ok {
+			return tcl.SetResult(RetOk, "0")
+		}
+		if i == len(args)-4 {
+			break
+		}
+		// Values that are not dictionaries have no keys.
+		dict, ok = tcl.parseDict(value)
+		if !ok {
+			return tcl.SetResult(RetOk, "0")
+		}
+	}
+	return tcl.SetResult(RetOk, "1")
+}
+
+// Return dictionary with only keys or values matching patterns, or for which
+// script returns true.
+func dictFilter(tcl *Tcl, args []string) int {
+	usage := "dict filter dictionary key|value ?pattern ...|script {keyVarName valueVarName} script"
+	if len(args) < 4 {
+		return tcl.SetResult(RetError, usage)
+	}
+	dict, ok := tcl.parseDict(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+
+	result := &tclDict{values: make(map[string]string)}
+	switch args[3] {
+	case "key", "value":
+		matched := make(map[string]bool)
+		for _, pattern := range args[4:] {
+			for _, key := range dict.matchKeys(pattern, args[3] == "value") {
+				matched[key] = true
+			}
+		}
+		// Keep order of original dictionary.
+		for _, key := range dict.keys {
+			if matched[key] {
+				result.set(key, dict.values[key])
+			}
+		}
+	case "script":
+		if len(args) != 6 {
+			return tcl.SetResult(RetError, usage)
+		}
+		vars := tcl.ParseArgs(args[4])
+		if len(vars) != 2 {
+			return tcl.SetResult(RetError, "must have exactly two variable names")
+		}
+		for _, key := range dict.keys {
+			tcl.SetVarValue(vars[0], key)
+			tcl.SetVarValue(vars[1], dict.values[key])
+			ret := tcl.eval(args[5], parserOptions{})
+			switch ret {
+			case RetOk:
+				v, ok := truthValue[tcl.result]
+				if !ok {
+					return tcl.SetResult(RetError, "expected boolean value but got \""+tcl.result+"\"")
+				}
+				if v {
+					result.set(key, dict.values[key])
+				}
+			case RetContinue:
+			case RetBreak:
+				return tcl.SetResult(RetOk, result.String())
+			default:
+				return ret
+			}
+		}
+	default:
+		return tcl.SetResult(RetError, usage)
+	}
+	return tcl.SetResult(RetOk, result.String())
+}
+
+// Run body for each key and value in dictionary.
+func dictFor(tcl *Tcl, args []string) int {
+	if len(args) != 5 {
+		return tcl.SetResult(RetError, "dict for {keyVarName valueVarName} dictionary body")
+	}
+	vars := tcl.ParseArgs(args[2])
+	if len(vars) != 2 {
+		return tcl.SetResult(RetError, "must have exactly two variable names")
+	}
+	dict, ok := tcl.parseDict(args[3])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+
+	for _, key := range dict.keys {
+		tcl.SetVarValue(vars[0], key)
+		tcl.SetVarValue(vars[1], dict.values[key])
+		ret := tcl.eval(args[4], parserOptions{})
+		switch ret {
+		case RetOk, RetContinue:
+		case RetBreak:
+			return tcl.SetResult(RetOk, "")
+		default:
+			return ret
+		}
+	}
+	return tcl.SetResult(RetOk, "")
+}
+
+// Return value at path of keys in nested dictionaries.
+func dictGet(tcl *Tcl, args []string) int {
+	usage := "dict get ?-default value dictionary ?key ..."
+	args = args[2:]
+	def := ""
+	hasDefault := false
+	if len(args) > 0 && args[0] == "-default" {
+		if len(args) < 2 {
+			return tcl.SetResult(RetError, usage)
+		}
+		def = args[1]
+		hasDefault = true
+		args = args[2:]
+	}
+	if len(args) < 1 {
+		return tcl.SetResult(RetError, usage)
+	}
+	value, found, msg := tcl.dictLookup(args[0], args[1:])
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+	if !found {
+		if !hasDefault {
+			return tcl.SetResult(RetError, "key \""+args[len(args)-1]+"\" not known in dictionary")
+		}
+		value = def
+	}
+	return tcl.SetResult(RetOk, value)
+}
+
+// Return value at path of keys, or default if path does not exist.
+func dictGetDef(tcl *Tcl, args []string) int {
+	if len(args) < 5 {
+		return tcl.SetResult(RetError, "dict getdef dictionary ?key ... key default")
+	}
+	value, found, msg := tcl.dictLookup(args[2], args[3:len(args)-1])
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+	if !found {
+		value = args[len(args)-1]
+	}
+	return tcl.SetResult(RetOk, value)
+}
+
+// Follow path of keys through nested dictionaries, returns value, whether it
+// was found and error message if a dictionary is not valid.
+func (tcl *Tcl) dictLookup(str string, keys []string) (string, bool, string) {
+	for _, key := range keys {
+		dict, ok := tcl.parseDict(str)
+		if !ok {
+			return "", false, "missing value to go with key"
+		}
+		str, ok = dict.values[key]
+		if !ok {
+			return "", false, ""
+		}
+	}
+	return str, true, ""
+}
+
+// Add increment to integer value stored under key in dictionary variable.
+func dictIncr(tcl *Tcl, args []string) int {
+	if len(args) != 4 && len(args) != 5 {
+		return tcl.SetResult(RetError, "dict incr dictVarName key ?increment")
+	}
+	dict, ok := tcl.dictVar(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+	incr := 1
+	if len(args) == 5 {
+		incr, ok = formatInteger(args[4])
+		if !ok {
+			return tcl.SetResult(RetError, "expected integer but got \""+args[4]+"\"")
+		}
+	}
+	value := 0
+	if str, found := dict.values[args[3]]; found {
+		value, ok = formatInteger(str)
+		if !ok {
+			return tcl.SetResult(RetError, "expected integer but got \""+str+"\"")
+		}
+	}
+	dict.set(args[3], ConvertNumberToString(value+incr, 10))
+	return tcl.storeDict(args[2], dict)
+}
+
+// Return list of keys matching pattern.
+func dictKeys(tcl *Tcl, args []string) int {
+	if len(args) != 3 && len(args) != 4 {
+		return tcl.SetResult(RetError, "dict keys dictionary ?pattern")
+	}
+	dict, ok := tcl.parseDict(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+	pattern := ""
+	if len(args) == 4 {
+		pattern = args[3]
+	}
+	return tcl.SetResult(RetOk, escapeList(dict.matchKeys(pattern, false)))
+}
+
+// Append values to list stored under key in dictionary variable.
+func dictLappend(tcl *Tcl, args []string) int {
+	if len(args) < 4 {
+		return tcl.SetResult(RetError, "dict lappend dictVarName key ?value ...")
+	}
+
+	// Variable that does not exist starts as empty dictionary.
+	dict, ok := tcl.dictVar(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+
+	list := dict.values[args[3]]
+	for _, value := range args[4:] {
+		if list != "" {
+			list += " "
+		}
+		list += StringEscape(value)
+	}
+	dict.set(args[3], list)
+	return tcl.storeDict(args[2], dict)
+}
+
+// Merge dictionaries, later values replace earlier ones.
+func dictMerge(tcl *Tcl, args []string) int {
+	result := &tclDict{values: make(map[string]string)}
+	for _, str := range args[2:] {
+		dict, ok := tcl.parseDict(str)
+		if !ok {
+			return tcl.SetResult(RetError, "missing value to go with key")
+		}
+		for _, key := range dict.keys {
+			result.set(key, dict.values[key])
+		}
+	}
+	return tcl.SetResult(RetOk, result.String())
+}
+
+// Return copy of dictionary with keys removed.
+func dictRemove(tcl *Tcl, args []string) int {
+	if len(args) < 3 {
+		return tcl.SetResult(RetError, "dict remove dictionary ?key ...")
+	}
+	dict, ok := tcl.parseDict(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+	for _, key := range args[3:] {
+		dict.remove(key)
+	}
+	return tcl.SetResult(RetOk, dict.String())
+}
+
+// Return copy of dictionary with keys set to new values.
+func dictReplace(tcl *Tcl, args []string) int {
+	if len(args) < 3 || len(args)%2 != 1 {
+		return tcl.SetResult(RetError, "dict replace dictionary ?key value ...")
+	}
+	dict, ok := tcl.parseDict(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+	for i := 3; i < len(args); i += 2 {
+		dict.set(args[i], args[i+1])
+	}
+	return tcl.SetResult(RetOk, dict.String())
+}
+
+// Set value at path of keys in dictionary variable, creating nested
+// dictionaries as needed.
+func dictSet(tcl *Tcl, args []string) int {
+	if len(args) < 5 {
+		return tcl.SetResult(RetError, "dict set dictVarName key ?key ... value")
+	}
+	ret, str := tcl.GetVarValue(args[2])
+	if ret != RetOk {
+		str = ""
+	}
+	str, msg := tcl.dictSetPath(str, args[3:len(args)-1], args[len(args)-1], false)
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+	if ret, msg := tcl.setVar(args[2], str); ret != RetOk {
+		return tcl.SetResult(ret, msg)
+	}
+	return tcl.SetResult(RetOk, str)
+}
+
+// Set or remove value at path of keys, returns new dictionary or error message.
+func (tcl *Tcl) dictSetPath(str string, keys []string, value string, remove bool) (string, string) {
+	dict, ok := tcl.parseDict(str)
+	if !ok {
+		return "", "missing value to go with key"
+	}
+	key := keys[0]
+	if len(keys) == 1 {
+		if remove {
+			dict.remove(key)
+		} else {
+			dict.set(key, value)
+		}
+		return dict.String(), ""
+	}
+	inner, found := dict.values[key]
+	if !found && remove {
+		return "", "key \"" + key + "\" not known in dictionary"
+	}
+	inner, msg := tcl.dictSetPath(inner, keys[1:], value, remove)
+	if msg != "" {
+		return "", msg
+	}
+	dict.set(key, inner)
+	return dict.String(), ""
+}
+
+// Return number of keys in dictionary.
+func dictSize(tcl *Tcl, args []string) int {
+	if len(args) != 3 {
+		return tcl.SetResult(RetError, "dict size dictionary")
+	}
+	dict, ok := tcl.parseDict(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(len(dict.keys), 10))
+}
+
+// Remove value at path of keys in dictionary variable.
+func dictUnset(tcl *Tcl, args []string) int {
+	if len(args) < 4 {
+		return tcl.SetResult(RetError, "dict unset dictVarName key ?key ...")
+	}
+	ret, str := tcl.GetVarValue(args[2])
+	if ret != RetOk {
+		str = ""
+	}
+	str, msg := tcl.dictSetPath(str, args[3:], "", true)
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+	if ret, msg := tcl.setVar(args[2], str); ret != RetOk {
+		return tcl.SetResult(ret, msg)
+	}
+	return tcl.SetResult(RetOk, str)
+}
+
+// Copy values of keys to variables, run body and store variables back
+// into dictionary. Variables that were unset remove their key.
+func dictUpdate(tcl *Tcl, args []string) int {
+	if len(args) < 6 || len(args)%2 != 0 {
+		return tcl.SetResult(RetError, "dict update dictVarName key varName ?key varName ... body")
+	}
+	dict, ok := tcl.dictVar(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+	pairs := args[3 : len(args)-1]
+	for i := 0; i < len(pairs); i += 2 {
+		if value, found := dict.values[pairs[i]]; found {
+			tcl.SetVarValue(pairs[i+1], value)
+		} else {
+			tcl.UnSetVar(pairs[i+1])
+		}
+	}
+
+	ret := tcl.eval(args[len(args)-1], parserOptions{})
+	result := tcl.result
+
+	// Dictionary may have been changed by body.
+	dict, ok = tcl.dictVar(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		if r, value := tcl.GetVarValue(pairs[i+1]); r == RetOk {
+			dict.set(pairs[i], value)
+		} else {
+			dict.remove(pairs[i])
+		}
+	}
+	if r := tcl.storeDict(args[2], dict); r != RetOk {
+		return r
+	}
+	return tcl.SetResult(ret, result)
+}
+
+// Return list of values matching pattern.
+func dictValues(tcl *Tcl, args []string) int {
+	if len(args) != 3 && len(args) != 4 {
+		return tcl.SetResult(RetError, "dict values dictionary ?pattern")
+	}
+	dict, ok := tcl.parseDict(args[2])
+	if !ok {
+		return tcl.SetResult(RetError, "missing value to go with key")
+	}
+	pattern := ""
+	if len(args) == 4 {
+		pattern = args[3]
+	}
+	values := []string{}
+	for _, key := range dict.matchKeys(pattern, true) {
+		values = append(values, dict.values[key])
+	}
+	return tcl.SetResult(RetOk, escapeList(values))
+}
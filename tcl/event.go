@@ -0,0 +1,274 @@
+/*
+ * TCL  event queue.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events waiting to be run by the interpreter.
+type eventQueue struct {
+	lock    sync.Mutex
+	pending []func(*Tcl) int // Events in order posted.
+	notify  chan struct{}    // Signaled when event posted.
+	sources []func() bool    // Report if an extension may post events later.
+}
+
+// Pending after commands.
+type timerList struct {
+	timers map[string]*tclTimer // Pending timers by id.
+	next   int                  // Number for next timer id.
+}
+
+// Script to run after a delay.
+type tclTimer struct {
+	script   string      // Script to run.
+	deadline time.Time   // When script is to run.
+	timer    *time.Timer // Timer, nil for idle scripts.
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{notify: make(chan struct{}, 1)}
+}
+
+func newTimerList() *timerList {
+	return &timerList{timers: make(map[string]*tclTimer)}
+}
+
+// Post an event to be run by interpreter. Can be called from any goroutine.
+func (tcl *Tcl) PostEvent(fn func(*Tcl) int) {
+	tcl.events.lock.Lock()
+	tcl.events.pending = append(tcl.events.pending, fn)
+	tcl.events.lock.Unlock()
+	select {
+	case tcl.events.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Add function which reports if an extension has handlers that may post
+// events later. Vwait fails if no event is pending and no source is active.
+func (tcl *Tcl) AddEventSource(active func() bool) {
+	tcl.events.sources = append(tcl.events.sources, active)
+}
+
+// Return true if an event is pending, or may be posted later.
+func (tcl *Tcl) eventsPossible() bool {
+	tcl.events.lock.Lock()
+	pending := len(tcl.events.pending)
+	tcl.events.lock.Unlock()
+	if pending != 0 || len(tcl.timers.timers) != 0 {
+		return true
+	}
+	for _, active := range tcl.events.sources {
+		if active() {
+			return true
+		}
+	}
+	return false
+}
+
+// Run any pending events, if wait is true wait for at least one event.
+// Returns number of events processed.
+func (tcl *Tcl) ProcessEvents(wait bool) int {
+	for {
+		tcl.events.lock.Lock()
+		pending := tcl.events.pending
+		tcl.events.pending = nil
+		tcl.events.lock.Unlock()
+
+		if len(pending) != 0 || !wait {
+			for _, fn := range pending {
+				tcl.runEvent(fn)
+			}
+			return len(pending)
+		}
+		<-tcl.events.notify
+	}
+}
+
+// Run event, errors are reported on error output since there is no caller.
+func (tcl *Tcl) runEvent(fn func(*Tcl) int) {
+	saveResult := tcl.result
+	ret := fn(tcl)
+	if ret == RetError {
+		fmt.Fprintln(tcl.stderr, "background error: "+tcl.result)
+	}
+	tcl.result = saveResult
+}
+
+// Note that a variable was written, used to end vwait.
+func (tcl *Tcl) varWritten(variable *tclVar) {
+	if tcl.waitVar == "" {
+		return
+	}
+	if v, ok := tcl.waitVars[tcl.waitVar]; ok && v == variable {
+		tcl.waitDone = true
+	}
+}
+
+// Process events until no more are pending.
+func cmdUpdate(tcl *Tcl, args []string) int {
+	if len(args) > 2 {
+		return tcl.SetResult(RetError, "update ?idletasks")
+	}
+	for tcl.ProcessEvents(false) != 0 {
+	}
+	return tcl.SetResult(RetOk, "")
+}
+
+// Process events until variable is written.
+func cmdVWait(tcl *Tcl, args []string) int {
+	if len(args) != 2 {
+		return tcl.SetResult(RetError, "vwait varName")
+	}
+	saveVars := tcl.waitVars
+	saveVar := tcl.waitVar
+	saveDone := tcl.waitDone
+
+	// Resolve name once, unqualified names are global.
+	tcl.waitVars, tcl.waitVar = tcl.getLevel(true, 0).vars, args[1]
+	if strings.Contains(args[1], "::") {
+		tcl.waitVars, tcl.waitVar = tcl.varTable(args[1])
+	}
+	tcl.waitDone = false
+	for !tcl.waitDone && tcl.eventsPossible() {
+		tcl.ProcessEvents(true)
+	}
+	done := tcl.waitDone
+	tcl.waitVars = saveVars
+	tcl.waitVar = saveVar
+	tcl.waitDone = saveDone
+	if !done {
+		return tcl.SetResult(RetError, "can't wait for variable \""+args[1]+"\": would wait forever")
+	}
+	return tcl.SetResult(RetOk, "")
+}
+
+// Run script after a delay, or manage pending scripts.
+func cmdAfter(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "after ms ?script ...")
+	}
+	switch args[1] {
+	case "cancel":
+		return afterCancel(tcl, args)
+	case "info":
+		return afterInfo(tcl, args)
+	case "idle":
+		if len(args) < 3 {
+			return tcl.SetResult(RetError, "after idle script ?script ...")
+		}
+		return tcl.SetResult(RetOk, tcl.addTimer(0, strings.Join(args[2:], " ")))
+	}
+
+	ms, pos, ok := ConvertStringToNumber(args[1], 10, 0)
+	if !ok || pos != len(args[1]) || ms < 0 {
+		return tcl.SetResult(RetError, "bad argument "+args[1]+": must be cancel, idle, info, or an integer")
+	}
+
+	// No script, just wait.
+	if len(args) == 2 {
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+		return tcl.SetResult(RetOk, "")
+	}
+	return tcl.SetResult(RetOk, tcl.addTimer(ms, strings.Join(args[2:], " ")))
+}
+
+// Schedule script to run after ms milliseconds, returns id of timer.
+func (tcl *Tcl) addTimer(ms int, script string) string {
+	id := "after#" + ConvertNumberToString(tcl.timers.next, 10)
+	tcl.timers.next++
+	delay := time.Duration(ms) * time.Millisecond
+	entry := &tclTimer{script: script, deadline: time.Now().Add(delay)}
+	tcl.timers.timers[id] = entry
+
+	run := func(t *Tcl) int {
+		// Skip if canceled before event was run.
+		if t.timers.timers[id] != entry {
+			return RetOk
+		}
+		delete(t.timers.timers, id)
+		return t.evalGlobal(script)
+	}
+
+	if ms == 0 {
+		tcl.PostEvent(run)
+	} else {
+		entry.timer = time.AfterFunc(delay, func() { tcl.PostEvent(run) })
+	}
+	return id
+}
+
+// Cancel pending timer, by id or by script.
+func afterCancel(tcl *Tcl, args []string) int {
+	if len(args) < 3 {
+		return tcl.SetResult(RetError, "after cancel id|script")
+	}
+	id := args[2]
+	if _, ok := tcl.timers.timers[id]; !ok {
+		script := strings.Join(args[2:], " ")
+		id = ""
+		for name, entry := range tcl.timers.timers {
+			if entry.script == script {
+				id = name
+				break
+			}
+		}
+	}
+	if entry, ok := tcl.timers.timers[id]; ok {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+		delete(tcl.timers.timers, id)
+	}
+	return tcl.SetResult(RetOk, "")
+}
+
+// Return list of pending timers, or script and remaining time for a timer.
+func afterInfo(tcl *Tcl, args []string) int {
+	switch len(args) {
+	case 2:
+		ids := []string{}
+		for id := range tcl.timers.timers {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return tcl.SetResult(RetOk, strings.Join(ids, " "))
+	case 3:
+		entry, ok := tcl.timers.timers[args[2]]
+		if !ok {
+			return tcl.SetResult(RetError, "event \""+args[2]+"\" doesn't exist")
+		}
+		remain := max(int(time.Until(entry.deadline)/time.Millisecond), 0)
+		return tcl.SetResult(RetOk, StringEscape(entry.script)+" "+ConvertNumberToString(remain, 10))
+	}
+	return tcl.SetResult(RetError, "after info ?id")
+}
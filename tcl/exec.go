@@ -0,0 +1,314 @@
+/*
+ * TCL  exec command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Run an external program and return its output.
+func cmdExec(tcl *Tcl, args []string) int {
+	usage := "exec ?-env list ?-envappend list ?-directory dir ?-keepnewline ?-stderrvar varName cmd ?arg ..."
+	var env []string
+	dir := ""
+	keepNewline := false
+	stderrVar := ""
+	i := 1
+outer:
+	for ; i < len(args); i++ {
+		switch args[i] {
+		case "-env", "-envappend":
+			if (i + 1) >= len(args) {
+				return tcl.SetResult(RetError, usage)
+			}
+			dict, ok := tcl.parseDict(args[i+1])
+			if !ok {
+				return tcl.SetResult(RetError, "environment list must have name value pairs")
+			}
+			if args[i] == "-envappend" && env == nil {
+				env = os.Environ()
+			}
+			if env == nil {
+				env = []string{}
+			}
+			for _, key := range dict.keys {
+				env = append(env, key+"="+dict.values[key])
+			}
+			i++
+		case "-directory":
+			if (i + 1) >= len(args) {
+				return tcl.SetResult(RetError, usage)
+			}
+			dir = args[i+1]
+			i++
+		case "-keepnewline":
+			keepNewline = true
+		case "-stderrvar":
+			if (i + 1) >= len(args) {
+				return tcl.SetResult(RetError, usage)
+			}
+			stderrVar = args[i+1]
+			i++
+		case "--":
+			i++
+			break outer
+		default:
+			break outer
+		}
+	}
+
+	if i >= len(args) {
+		return tcl.SetResult(RetError, usage)
+	}
+
+	// Split command words into pipeline stages, and collect redirections.
+	stages := [][]string{{}}
+	pipeErr := []bool{}
+	background := false
+	redir := execRedirect{}
+	for j := i; j < len(args); j++ {
+		word := args[j]
+		switch {
+		case word == "|" || word == "|&":
+			if len(stages[len(stages)-1]) == 0 {
+				return tcl.SetResult(RetError, "illegal use of | or |& in command")
+			}
+			pipeErr = append(pipeErr, word == "|&")
+			stages = append(stages, []string{})
+			continue
+		case word == "&" && j == len(args)-1:
+			background = true
+			continue
+		}
+
+		target := ""
+		kind := ""
+		for _, op := range []string{"2>@1", ">&", "2>", ">"} {
+			if strings.HasPrefix(word, op) {
+				kind = op
+				target = word[len(op):]
+				break
+			}
+		}
+		if word == "-stderrvar" && j > i {
+			kind = word
+		}
+		if kind == "" || kind == "2>@1" && target != "" {
+			stages[len(stages)-1] = append(stages[len(stages)-1], word)
+			continue
+		}
+		if kind != "2>@1" && target == "" {
+			j++
+			if j >= len(args) {
+				return tcl.SetResult(RetError, "can't specify \""+word+"\" as last word in command")
+			}
+			target = args[j]
+		}
+		switch kind {
+		case "2>@1":
+			redir.merge = true
+		case ">&":
+			redir.stdout = target
+			redir.merge = true
+		case ">":
+			redir.stdout = target
+		case "2>":
+			redir.stderr = target
+		case "-stderrvar":
+			stderrVar = target
+		}
+	}
+	if len(stages[len(stages)-1]) == 0 {
+		if len(stages) > 1 {
+			return tcl.SetResult(RetError, "illegal use of | or |& in command")
+		}
+		return tcl.SetResult(RetError, usage)
+	}
+
+	// Each stage has its own error output, as stages run at the same time.
+	cmds := make([]*exec.Cmd, len(stages))
+	errBufs := make([]bytes.Buffer, len(stages))
+	for k, words := range stages {
+		cmds[k] = exec.Command(words[0], words[1:]...)
+		cmds[k].Env = env
+		cmds[k].Dir = dir
+		cmds[k].Stderr = &errBufs[k]
+	}
+	last := cmds[len(cmds)-1]
+	var stdout bytes.Buffer
+	last.Stdout = &stdout
+	if background {
+		last.Stdout = tcl.stdout
+		for _, cmd := range cmds {
+			cmd.Stderr = tcl.stderr
+		}
+	}
+
+	files, err := redir.open(cmds)
+	if err != nil {
+		return tcl.SetResult(RetError, "couldn't open redirection: "+err.Error())
+	}
+
+	// Connect output of each stage to input of the next.
+	pipes := []*os.File{}
+	for k := 0; k < len(cmds)-1; k++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closeFiles(pipes)
+			closeFiles(files)
+			return tcl.SetResult(RetError, "couldn't create pipe: "+err.Error())
+		}
+		pipes = append(pipes, r, w)
+		cmds[k].Stdout = w
+		if pipeErr[k] {
+			cmds[k].Stderr = w
+		}
+		cmds[k+1].Stdin = r
+	}
+
+	for k, cmd := range cmds {
+		if err := cmd.Start(); err != nil {
+			closeFiles(pipes)
+			for _, started := range cmds[:k] {
+				_ = started.Process.Kill()
+				_ = started.Wait()
+			}
+			closeFiles(files)
+			return tcl.SetResult(RetError, "couldn't execute \""+stages[k][0]+"\": "+err.Error())
+		}
+	}
+	closeFiles(pipes)
+
+	// Background pipelines return process ids, and are waited for later.
+	if background {
+		pids := []string{}
+		for _, cmd := range cmds {
+			pids = append(pids, ConvertNumberToString(cmd.Process.Pid, 10))
+		}
+		go func() {
+			for _, cmd := range cmds {
+				_ = cmd.Wait()
+			}
+			closeFiles(files)
+		}()
+		return tcl.SetResult(RetOk, strings.Join(pids, " "))
+	}
+
+	var failed *exec.Cmd
+	for _, cmd := range cmds {
+		if werr := cmd.Wait(); werr != nil && err == nil {
+			err = werr
+			failed = cmd
+		}
+	}
+	closeFiles(files)
+
+	var stderr strings.Builder
+	for k := range errBufs {
+		stderr.Write(errBufs[k].Bytes())
+	}
+	output := stdout.String()
+	errOutput := stderr.String()
+	if !keepNewline {
+		output = strings.TrimSuffix(output, "\n")
+		errOutput = strings.TrimSuffix(errOutput, "\n")
+	}
+
+	// Error output goes to variable if requested.
+	if stderrVar != "" {
+		if ret, msg := tcl.setVar(stderrVar, errOutput); ret != RetOk {
+			return tcl.SetResult(ret, msg)
+		}
+		stderr.Reset()
+	}
+
+	// Any error output is an error, exit status is put in errorCode.
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		tcl.SetErrorCode("CHILDSTATUS " + ConvertNumberToString(failed.Process.Pid, 10) + " " +
+			ConvertNumberToString(exitErr.ExitCode(), 10))
+	}
+	switch {
+	case stderr.Len() != 0:
+		return tcl.SetResult(RetError, errOutput)
+	case exitErr != nil:
+		return tcl.SetResult(RetError, "child process exited abnormally")
+	case err != nil:
+		return tcl.SetResult(RetError, err.Error())
+	}
+	return tcl.SetResult(RetOk, output)
+}
+
+// Close files that are open.
+func closeFiles(files []*os.File) {
+	for _, file := range files {
+		if file != nil {
+			file.Close()
+		}
+	}
+}
+
+// Output redirections of exec.
+type execRedirect struct {
+	stdout string // File for standard output.
+	stderr string // File for error output.
+	merge  bool   // Error output goes with standard output.
+}
+
+// Attach redirections to pipeline, output goes from last command and error
+// output from every command. Returns files to close after commands run.
+func (redir execRedirect) open(cmds []*exec.Cmd) ([]*os.File, error) {
+	files := []*os.File{}
+	for _, name := range []string{redir.stdout, redir.stderr} {
+		if name == "" {
+			files = append(files, nil)
+			continue
+		}
+		file, err := os.Create(name)
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	last := cmds[len(cmds)-1]
+	if files[0] != nil {
+		last.Stdout = files[0]
+	}
+	if files[1] != nil {
+		for _, cmd := range cmds {
+			cmd.Stderr = files[1]
+		}
+	}
+	if redir.merge {
+		last.Stderr = last.Stdout
+	}
+	return files, nil
+}
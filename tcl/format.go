@@ -0,0 +1,162 @@
+/*
+ * TCL  format command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format a string, printf style.
+func cmdFormat(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "format formatString ?arg ...")
+	}
+	res, err := formatString(args[1], args[2:])
+	if err != "" {
+		return tcl.SetResult(RetError, err)
+	}
+	return tcl.SetResult(RetOk, res)
+}
+
+// Format string, alias for format command.
+func stringFormat(tcl *Tcl, args []string) int {
+	return cmdFormat(tcl, args[1:])
+}
+
+// Convert format string using arguments, returns result or error message.
+func formatString(format string, args []string) (string, string) {
+	result := ""
+	arg := 0
+	for pos := 0; pos < len(format); pos++ {
+		if format[pos] != '%' {
+			result += string(format[pos])
+			continue
+		}
+
+		// Collect flags, width and precision.
+		spec := "%"
+		pos++
+		for pos < len(format) && strings.ContainsRune("-+ 0#", rune(format[pos])) {
+			spec += string(format[pos])
+			pos++
+		}
+		var err string
+		spec, pos, arg, err = formatWidth(format, pos, spec, args, arg)
+		if err != "" {
+			return "", err
+		}
+		if pos < len(format) && format[pos] == '.' {
+			spec += "."
+			spec, pos, arg, err = formatWidth(format, pos+1, spec, args, arg)
+			if err != "" {
+				return "", err
+			}
+		}
+
+		// Size modifiers have no meaning here.
+		for pos < len(format) && (format[pos] == 'l' || format[pos] == 'h') {
+			pos++
+		}
+		if pos >= len(format) {
+			return "", "format string ended in middle of field specifier"
+		}
+
+		conv := format[pos]
+		if conv == '%' {
+			result += "%"
+			continue
+		}
+		if arg >= len(args) {
+			return "", "not enough arguments for all format specifiers"
+		}
+		value := args[arg]
+		arg++
+
+		switch conv {
+		case 's':
+			result += fmt.Sprintf(spec+"s", value)
+		case 'd', 'i', 'b', 'o', 'x', 'X', 'c':
+			num, ok := formatInteger(value)
+			if !ok {
+				return "", "expected integer but got \"" + value + "\""
+			}
+			if conv == 'i' {
+				conv = 'd'
+			}
+			result += fmt.Sprintf(spec+string(conv), num)
+		case 'f', 'e', 'E', 'g', 'G':
+			num, ok := formatFloat(value)
+			if !ok {
+				return "", "expected floating-point number but got \"" + value + "\""
+			}
+			result += fmt.Sprintf(spec+string(conv), num)
+		default:
+			return "", "bad field specifier \"" + string(conv) + "\""
+		}
+	}
+	return result, ""
+}
+
+// Convert argument to integer, the whole string must be a number.
+func formatInteger(str string) (int, bool) {
+	str = strings.TrimSpace(str)
+	num, pos, ok := ConvertStringToNumber(str, 10, 0)
+	if !ok || pos != len(str) {
+		return 0, false
+	}
+	return num, true
+}
+
+// Convert argument to floating point, integers are also accepted.
+func formatFloat(str string) (float64, bool) {
+	str = strings.TrimSpace(str)
+	if num, pos, ok := ConvertStringToFloat(str, 0); ok && pos == len(str) {
+		return num, true
+	}
+	num, ok := formatInteger(str)
+	return float64(num), ok
+}
+
+// Collect width or precision digits, * takes the value from the next argument.
+// Returns updated spec, position and argument index or error message.
+func formatWidth(format string, pos int, spec string, args []string, arg int) (string, int, int, string) {
+	if pos < len(format) && format[pos] == '*' {
+		if arg >= len(args) {
+			return spec, pos, arg, "not enough arguments for all format specifiers"
+		}
+		width, ok := formatInteger(args[arg])
+		if !ok {
+			return spec, pos, arg, "expected integer but got \"" + args[arg] + "\""
+		}
+		return spec + ConvertNumberToString(width, 10), pos + 1, arg + 1, ""
+	}
+	for pos < len(format) && format[pos] >= '0' && format[pos] <= '9' {
+		spec += string(format[pos])
+		pos++
+	}
+	return spec, pos, arg, ""
+}
@@ -26,6 +26,8 @@
 package tcl
 
 import (
+	"os"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -121,19 +123,19 @@ func UnEscape(str string) (string, int) {
 				num = 0
 
 			default:
-				result += string(ch)
+				result += str[pos : pos+1]
 			}
 			inEscape = false
 		} else {
 			if ch == '\\' {
 				inEscape = true
 			} else {
-				result += string(ch)
+				result += str[pos : pos+1]
 			}
 		}
 	}
 
-	if digits > 0 {
+	if inOctal {
 		result += string(rune(num))
 	}
 	return result, 0
@@ -204,6 +206,8 @@ func ConvertStringToNumber(str string, base int, pos int) (int, int, bool) {
 func ConvertNumberToString(num int, base int) string {
 	result := ""
 	neg := false
+	// Digits are generated unsigned so the most negative number converts.
+	value := uint(num)
 	// Add based on base.
 	switch base {
 	case 8:
@@ -213,12 +217,12 @@ func ConvertNumberToString(num int, base int) string {
 	default:
 		if num < 0 {
 			neg = true
-			num = -num
+			value = -value
 		}
 	}
 
 	// If number is zero append 0 and return.
-	if num == 0 {
+	if value == 0 {
 		if base != 8 {
 			result += "0"
 		}
@@ -226,11 +230,13 @@ func ConvertNumberToString(num int, base int) string {
 	}
 
 	// Prepends digits to number.
-	for num != 0 {
-		d := num % base
-		result = string(hex[d]) + result
-		num /= base
+	digits := ""
+	for value != 0 {
+		d := value % uint(base)
+		digits = string(hex[d]) + digits
+		value /= uint(base)
 	}
+	result += digits
 
 	// Put negative sign if negative.
 	if neg {
@@ -239,32 +245,249 @@ func ConvertNumberToString(num int, base int) string {
 	return result
 }
 
+// Convert a floating point number at pos, return value, last position scanned
+// and whether a number with a decimal point or exponent was found.
+func ConvertStringToFloat(str string, pos int) (float64, int, bool) {
+	start := pos
+	for start < len(str) && unicode.IsSpace(rune(str[start])) {
+		start++
+	}
+	end := start
+	if end < len(str) && (str[end] == '-' || str[end] == '+') {
+		end++
+	}
+	digits := 0
+	isFloat := false
+	for end < len(str) && (unicode.IsDigit(rune(str[end])) || str[end] == '.') {
+		if str[end] == '.' {
+			if isFloat {
+				break
+			}
+			isFloat = true
+		} else {
+			digits++
+		}
+		end++
+	}
+	if digits == 0 {
+		return 0, pos, false
+	}
+
+	// Optional exponent.
+	if end < len(str) && (str[end] == 'e' || str[end] == 'E') {
+		exp := end + 1
+		if exp < len(str) && (str[exp] == '-' || str[exp] == '+') {
+			exp++
+		}
+		if exp < len(str) && unicode.IsDigit(rune(str[exp])) {
+			for exp < len(str) && unicode.IsDigit(rune(str[exp])) {
+				exp++
+			}
+			end = exp
+			isFloat = true
+		}
+	}
+	if !isFloat {
+		return 0, pos, false
+	}
+	value, err := strconv.ParseFloat(str[start:end], 64)
+	if err != nil {
+		return 0, pos, false
+	}
+	return value, end, true
+}
+
+// Convert floating point number to string, always showing it is not integer.
+func ConvertFloatToString(num float64) string {
+	result := strconv.FormatFloat(num, 'g', -1, 64)
+	if !strings.ContainsAny(result, ".eIN") {
+		result += ".0"
+	}
+	return result
+}
+
 // Set a variable to value, create variable if it does not exist.
 func (tcl *Tcl) SetVarValue(name string, value string) {
-	variable, ok := tcl.env.vars[name]
-	if !ok {
-		variable = &tclVar{value: value}
-		tcl.env.vars[name] = variable
-	} else {
-		variable.value = value
-	}
+	tcl.setVar(name, value)
 }
 
 // Remove a variable from current environment.
 func (tcl *Tcl) UnSetVar(name string) {
-	delete(tcl.env.vars, name)
-	delete(tcl.env.local, name)
+	arrayName, index, isArray := splitArrayName(name)
+	vars, base := tcl.varTable(arrayName)
+	if vars == nil {
+		return
+	}
+	variable, ok := vars[base]
+	if isArray {
+		if ok && variable.array != nil {
+			element := variable.array[index]
+			delete(variable.array, index)
+			if variable == tcl.environ {
+				_ = os.Unsetenv(index)
+			}
+			tcl.traceVar(variable, element, arrayName, index, "unset")
+		}
+		return
+	}
+	delete(vars, base)
+	if !strings.Contains(name, "::") {
+		delete(tcl.env.local, base)
+	}
+	if ok {
+		tcl.traceVar(variable, nil, arrayName, "", "unset")
+	}
 }
 
 // Retrieve a value of a variable.
 func (tcl *Tcl) GetVarValue(name string) (int, string) {
-	variable, ok := tcl.env.vars[name]
+	arrayName, index, isArray := splitArrayName(name)
+	vars, base := tcl.varTable(arrayName)
+	variable, ok := vars[base]
 	if !ok {
 		return RetError, "value: " + name + " not found"
 	}
+	if isArray {
+		if variable.array == nil {
+			return RetError, "can't read \"" + name + "\": variable isn't array"
+		}
+		if variable == tcl.environ {
+			value, ok := os.LookupEnv(index)
+			if !ok {
+				return RetError, "can't read \"" + name + "\": no such variable"
+			}
+			return RetOk, value
+		}
+		element := variable.array[index]
+		if ret, msg := tcl.traceVar(variable, element, arrayName, index, "read"); ret != RetOk {
+			return ret, "can't read \"" + name + "\": " + msg
+		}
+		element, ok = variable.array[index]
+		if !ok || element.undefined {
+			return RetError, "value: " + name + " not found"
+		}
+		return RetOk, element.value
+	} else if variable.array != nil {
+		return RetError, "can't read \"" + name + "\": variable is array"
+	}
+	if ret, msg := tcl.traceVar(variable, nil, arrayName, "", "read"); ret != RetOk {
+		return ret, "can't read \"" + name + "\": " + msg
+	}
+	if variable.undefined {
+		return RetError, "value: " + name + " not found"
+	}
 	return RetOk, variable.value
 }
 
+// Report whether variable or array element has a value, without running traces.
+func (tcl *Tcl) varExists(name string) bool {
+	arrayName, index, isArray := splitArrayName(name)
+	vars, base := tcl.varTable(arrayName)
+	variable, ok := vars[base]
+	if !ok {
+		return false
+	}
+	if !isArray {
+		return variable.array == nil && !variable.undefined
+	}
+	if variable.array == nil {
+		return false
+	}
+	if variable == tcl.environ {
+		_, ok := os.LookupEnv(index)
+		return ok
+	}
+	element, ok := variable.array[index]
+	return ok && !element.undefined
+}
+
+// Set a variable to value, return error if variable can't be set.
+func (tcl *Tcl) setVar(name string, value string) (int, string) {
+	arrayName, index, isArray := splitArrayName(name)
+	vars, base := tcl.varTable(arrayName)
+	if vars == nil {
+		return RetError, "can't set \"" + name + "\": parent namespace doesn't exist"
+	}
+	variable, ok := vars[base]
+	if !ok {
+		variable = &tclVar{}
+		if isArray {
+			variable.array = make(map[string]*tclVar)
+		}
+		vars[base] = variable
+	}
+
+	// Element is nil for scalar variables.
+	var element *tclVar
+	if isArray {
+		if variable.array == nil {
+			return RetError, "can't set \"" + name + "\": variable isn't array"
+		}
+		element, ok = variable.array[index]
+		if !ok {
+			element = &tclVar{}
+			variable.array[index] = element
+		}
+		element.value = value
+		element.undefined = false
+		if variable == tcl.environ {
+			_ = os.Setenv(index, value)
+		}
+	} else {
+		if variable.array != nil {
+			return RetError, "can't set \"" + name + "\": variable is array"
+		}
+		variable.value = value
+	}
+	variable.undefined = false
+	tcl.varWritten(variable)
+
+	// Traces may change the value that was set.
+	if ret, msg := tcl.traceVar(variable, element, arrayName, index, "write"); ret != RetOk {
+		return ret, "can't set \"" + name + "\": " + msg
+	}
+	if element != nil {
+		return RetOk, element.value
+	}
+	return RetOk, variable.value
+}
+
+// Load env array with current process environment.
+func (tcl *Tcl) loadEnviron() {
+	clear(tcl.environ.array)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		tcl.environ.array[name] = &tclVar{value: value}
+	}
+}
+
+// Split variable name into array name and index.
+func splitArrayName(name string) (string, string, bool) {
+	pos := strings.IndexByte(name, '(')
+	if pos <= 0 || !strings.HasSuffix(name, ")") {
+		return name, "", false
+	}
+	return name[:pos], name[pos+1 : len(name)-1], true
+}
+
+// Return table holding variable, and name of variable in table.
+// Qualified names are found in their namespace.
+func (tcl *Tcl) varTable(name string) (map[string]*tclVar, string) {
+	pos := strings.LastIndex(name, "::")
+	if pos < 0 {
+		return tcl.env.vars, name
+	}
+	nsName := name[:pos]
+	if nsName == "" {
+		nsName = "::"
+	}
+	ns := tcl.findNamespace(nsName, false)
+	if ns == nil {
+		return nil, name
+	}
+	return ns.vars, name[pos+2:]
+}
+
 // Does this string need to be escaped.
 func StringEscape(str string) string {
 	if str == "" {
@@ -420,11 +643,71 @@ outer:
 	return k
 }
 
+// Report whether pattern matches all of target.
+func globMatch(pat string, target string) bool {
+	for i := 0; i < len(pat); i++ {
+		switch pat[i] {
+		case '*': // Match any number of characters.
+			for k := 0; k <= len(target); k++ {
+				if globMatch(pat[i+1:], target[k:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?': // Match any single character.
+			if target == "" {
+				return false
+			}
+			target = target[1:]
+
+		case '[': // Match any of the enclosed characters or ranges.
+			end := strings.IndexByte(pat[i+1:], ']')
+			if target == "" || end < 0 {
+				return false
+			}
+			set := pat[i+1 : i+1+end]
+			found := false
+			for j := 0; j < len(set); j++ {
+				first, last := set[j], set[j]
+				if j+2 < len(set) && set[j+1] == '-' {
+					last = set[j+2]
+					j += 2
+				}
+				if target[0] >= first && target[0] <= last {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+			target = target[1:]
+			i += end + 1
+
+		case '\\': // Escape character.
+			i++
+			if i >= len(pat) {
+				return false
+			}
+			fallthrough
+		default:
+			if target == "" || pat[i] != target[0] {
+				return false
+			}
+			target = target[1:]
+		}
+	}
+	return target == ""
+}
+
 // Create new environment, used in user procs.
 func (tcl *Tcl) newEnv() *tclEnv {
 	newEnv := &tclEnv{level: tcl.level}
 	newEnv.vars = make(map[string]*tclVar)
 	newEnv.local = make(map[string]bool)
+	if tcl.env != nil {
+		newEnv.ns = tcl.env.ns
+	}
 	return newEnv
 }
 
@@ -447,6 +730,27 @@ func (tcl *Tcl) popEnv() {
 	tcl.level--
 }
 
+// Evaluate script at global level, used by extensions to run event scripts.
+func (tcl *Tcl) EvalGlobal(script string) int {
+	return tcl.evalGlobal(script)
+}
+
+// Evaluate script at global level.
+func (tcl *Tcl) evalGlobal(script string) int {
+	global := tcl.env
+	for global.parent != nil {
+		global = global.parent
+	}
+	saveEnv := tcl.env
+	saveLevel := tcl.level
+	tcl.env = global
+	tcl.level = global.level
+	ret := tcl.eval(script, parserOptions{})
+	tcl.env = saveEnv
+	tcl.level = saveLevel
+	return ret
+}
+
 // Return pointer to environment at a given level.
 func (tcl *Tcl) getLevel(top bool, level int) *tclEnv {
 	if top {
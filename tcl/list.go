@@ -26,6 +26,7 @@
 package tcl
 
 import (
+	"cmp"
 	"regexp"
 	"strings"
 	"unicode"
@@ -33,6 +34,9 @@ import (
 
 // Join list items in first argument, with second argument.
 func cmdList(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetOk, "")
+	}
 	str := ""
 
 	for _, item := range args[1:] {
@@ -61,7 +65,7 @@ func convertListIndex(str string, listMax int, pos int) (int, int, bool) {
 	r := false
 	end := false
 	// Skip leading spaces.
-	for unicode.IsSpace(rune(str[pos])) && pos < len(str) {
+	for pos < len(str) && unicode.IsSpace(rune(str[pos])) {
 		pos++
 	}
 	if pos >= len(str) {
@@ -124,14 +128,18 @@ func cmdLIndex(tcl *Tcl, args []string) int {
 			if !ok {
 				break
 			}
-			if i < len(list) {
-				list = tcl.ParseArgs(list[i])
+			if i < 0 || i >= len(list) || (len(list) == 1 && list[0] == "") {
+				return tcl.SetResult(RetOk, "")
 			}
+			list = tcl.ParseArgs(list[i])
 			pos = npos
 			pending = true
 		}
 	}
 	if pending {
+		if len(list) == 1 && list[0] == "" {
+			return tcl.SetResult(RetOk, "")
+		}
 		res = append(res, list...)
 	}
 
@@ -156,8 +164,11 @@ func cmdLRange(tcl *Tcl, args []string) int {
 	}
 
 	// Convert indexes to valid ranges.
-	first = max(min(first, len(list)), 0)
-	last = max(min(last, len(list)), 0)
+	first = max(first, 0)
+	last = min(last, len(list)-1)
+	if args[1] == "" || first > last {
+		return tcl.SetResult(RetOk, "")
+	}
 	res := []string{"list"}
 	res = append(res, list[first:last+1]...)
 
@@ -172,13 +183,22 @@ func cmdLAppend(tcl *Tcl, args []string) int {
 		return RetError
 	}
 	name := args[1]
-	str := strings.Join(args[2:], " ")
-	ret, result := tcl.GetVarValue(name)
-	if ret == RetOk && result != "" {
-		str = result + " " + str
+
+	// Variable that does not exist starts as empty list.
+	ret, str := tcl.GetVarValue(name)
+	if ret != RetOk {
+		str = ""
+	}
+	for _, value := range args[2:] {
+		if str != "" {
+			str += " "
+		}
+		str += StringEscape(value)
 	}
 
-	tcl.SetVarValue(name, str)
+	if ret, msg := tcl.setVar(name, str); ret != RetOk {
+		return tcl.SetResult(ret, msg)
+	}
 	return tcl.SetResult(RetOk, str)
 }
 
@@ -203,6 +223,61 @@ func cmdLInsert(tcl *Tcl, args []string) int {
 	return cmdList(tcl, newList)
 }
 
+// Assign list elements to variables, returns remaining elements.
+func cmdLAssign(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "lassign list ?varName ...")
+	}
+	var list []string
+	if strings.TrimSpace(args[1]) != "" {
+		list = tcl.ParseArgs(args[1])
+	}
+	for i, name := range args[2:] {
+		value := ""
+		if i < len(list) {
+			value = list[i]
+		}
+		if ret, msg := tcl.setVar(name, value); ret != RetOk {
+			return tcl.SetResult(ret, msg)
+		}
+	}
+	if len(list) <= len(args)-2 {
+		return tcl.SetResult(RetOk, "")
+	}
+	return cmdList(tcl, append([]string{"list"}, list[len(args)-2:]...))
+}
+
+// Build list of values repeated count times.
+func cmdLRepeat(tcl *Tcl, args []string) int {
+	if len(args) < 3 {
+		return tcl.SetResult(RetError, "lrepeat count value ?value ...")
+	}
+	count, ok := formatInteger(args[1])
+	if !ok || count < 0 {
+		return tcl.SetResult(RetError, "bad count \""+args[1]+"\": must be integer >= 0")
+	}
+	list := []string{"list"}
+	for range count {
+		list = append(list, args[2:]...)
+	}
+	return cmdList(tcl, list)
+}
+
+// Return list with elements in reverse order.
+func cmdLReverse(tcl *Tcl, args []string) int {
+	if len(args) != 2 {
+		return tcl.SetResult(RetError, "lreverse list")
+	}
+	list := []string{"list"}
+	if strings.TrimSpace(args[1]) != "" {
+		items := tcl.ParseArgs(args[1])
+		for i := len(items) - 1; i >= 0; i-- {
+			list = append(list, items[i])
+		}
+	}
+	return cmdList(tcl, list)
+}
+
 // Replace elements of list with new elements.
 func cmdLReplace(tcl *Tcl, args []string) int {
 	if len(args) < 3 {
@@ -232,99 +307,232 @@ func cmdLReplace(tcl *Tcl, args []string) int {
 	return cmdList(tcl, newList)
 }
 
-// Compare two elements.
-func (tcl *Tcl) order(integer bool, reverse bool, command, a, b string) (bool, int) {
-	r := false
+// How lsort compares elements.
+type sortOptions struct {
+	integer bool   // Compare as integers.
+	real    bool   // Compare as floating point numbers.
+	nocase  bool   // Ignore case of strings.
+	reverse bool   // Sort in decreasing order.
+	unique  bool   // Remove duplicate elements.
+	command string // Command used to compare elements.
+	index   string // Index of sub-element to compare, empty for whole element.
+	stride  int    // Number of elements sorted as a group.
+}
 
+// Compare two elements, returns -1, 0 or 1 and result code.
+func (tcl *Tcl) compare(opts sortOptions, a, b string) (int, int) {
 	// If we have compare function use that.
-	if command != "" {
-		str := command + " " + StringEscape(a) + " " + StringEscape(b)
-		str += " " + b
+	if opts.command != "" {
+		str := strings.Join([]string{opts.command, StringEscape(a), StringEscape(b)}, " ")
 
 		ret := tcl.eval(str, parserOptions{})
 		if ret != RetOk {
-			return false, ret
+			return 0, ret
 		}
 
 		v, _, ok := ConvertStringToNumber(tcl.GetResult(), 10, 0)
 		if !ok {
-			return false, RetError
-		}
-		if reverse {
-			v = -1 * v
+			return 0, RetError
 		}
-		return v < 0, RetOk
+		return v, RetOk
 	}
 
-	// If not do integer or string compare
-	if integer {
+	// If not do number or string compare
+	switch {
+	case opts.integer:
 		ia, _, aok := ConvertStringToNumber(a, 10, 0)
 		ib, _, bok := ConvertStringToNumber(b, 10, 0)
 		if !aok || !bok {
-			return false, RetError
+			return 0, RetError
 		}
-		r = ia < ib
-	} else {
-		r = a < b
+		return cmp.Compare(ia, ib), RetOk
+	case opts.real:
+		fa, aok := formatFloat(a)
+		fb, bok := formatFloat(b)
+		if !aok || !bok {
+			return 0, RetError
+		}
+		return cmp.Compare(fa, fb), RetOk
+	case opts.nocase:
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b)), RetOk
 	}
-	return reverse != r, RetOk
+	return strings.Compare(a, b), RetOk
+}
+
+// Return true if a sorts before b.
+func (tcl *Tcl) order(opts sortOptions, a, b string) (bool, int) {
+	order, ret := tcl.compare(opts, a, b)
+	if opts.reverse {
+		order = -order
+	}
+	return order < 0, ret
 }
 
 // Sort a list.
 func cmdLSort(tcl *Tcl, args []string) int {
-	integer := false
-	reverse := false
-	command := ""
+	opts := sortOptions{stride: 1}
 	i := 1
 outer:
 	for ; i < len(args); i++ {
 		switch args[i] {
 		case "-increasing":
-			reverse = false
+			opts.reverse = false
 		case "-decreasing":
-			reverse = true
+			opts.reverse = true
 		case "-ascii":
-			integer = false
+			opts.integer = false
+			opts.real = false
 		case "-integer":
-			integer = true
+			opts.integer = true
+			opts.real = false
+		case "-real":
+			opts.real = true
+			opts.integer = false
+		case "-nocase":
+			opts.nocase = true
+		case "-unique":
+			opts.unique = true
 		case "-command":
 			i++
 			if i >= len(args) {
 				return tcl.SetResult(RetError, "missing command argument")
 			}
-			command = args[i]
+			opts.command = args[i]
+		case "-index":
+			i++
+			if i >= len(args) {
+				return tcl.SetResult(RetError, "missing index argument")
+			}
+			opts.index = args[i]
+		case "-stride":
+			i++
+			if i >= len(args) {
+				return tcl.SetResult(RetError, "missing stride argument")
+			}
+			stride, ok := formatInteger(args[i])
+			if !ok || stride < 1 {
+				return tcl.SetResult(RetError, "stride length must be at least 1")
+			}
+			opts.stride = stride
 		default:
 			break outer
 		}
 	}
+	if i >= len(args) {
+		return tcl.SetResult(RetError, "lsort ?options list")
+	}
 	list := tcl.ParseArgs(args[i])
+	if len(list)%opts.stride != 0 {
+		return tcl.SetResult(RetError, "list size must be a multiple of the stride length")
+	}
+
+	// Sort groups of stride elements.
+	groups := make([][]string, len(list)/opts.stride)
+	for j := range groups {
+		groups[j] = list[j*opts.stride : (j+1)*opts.stride]
+	}
+	keys, msg := tcl.sortKeys(groups, opts.index, opts.stride)
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
 	k := 0
-	for j := 1; j < len(list); j++ {
-		key := list[j]
+	for j := 1; j < len(groups); j++ {
+		group, key := groups[j], keys[j]
 		k = j - 1
 		for k >= 0 {
-			ord, err := tcl.order(integer, reverse, command, key, list[k])
+			ord, err := tcl.order(opts, key, keys[k])
 			if err != RetOk {
 				return err
 			}
 			if !ord {
 				break
 			}
-			list[k+1] = list[k]
+			groups[k+1], keys[k+1] = groups[k], keys[k]
 			k--
 		}
-		list[k+1] = key
+		groups[k+1], keys[k+1] = group, key
 	}
-	return cmdList(tcl, append([]string{"list"}, list...))
+
+	// Equal groups are adjacent after sorting, keep first of each.
+	if opts.unique && len(groups) > 1 {
+		unique := groups[:1]
+		last := keys[0]
+		for j, group := range groups[1:] {
+			order, err := tcl.compare(opts, last, keys[j+1])
+			if err != RetOk {
+				return err
+			}
+			if order != 0 {
+				unique = append(unique, group)
+				last = keys[j+1]
+			}
+		}
+		groups = unique
+	}
+
+	result := []string{"list"}
+	for _, group := range groups {
+		result = append(result, group...)
+	}
+	return cmdList(tcl, result)
+}
+
+// Return keys to sort groups by. Without index the first element of group is
+// used. Groups of one element are indexed as a list, larger groups by
+// position in group.
+func (tcl *Tcl) sortKeys(groups [][]string, index string, stride int) ([]string, string) {
+	keys := make([]string, len(groups))
+	for i, group := range groups {
+		if index == "" {
+			keys[i] = group[0]
+			continue
+		}
+		items := group
+		if stride == 1 {
+			items = tcl.ParseArgs(group[0])
+		}
+		n, pos, ok := convertListIndex(index, len(items), 0)
+		if !ok || pos != len(index) {
+			return nil, "bad index \"" + index + "\""
+		}
+		if n < 0 || n >= len(items) {
+			if stride == 1 {
+				return nil, "element " + index + " missing from sublist \"" + group[0] + "\""
+			}
+			return nil, "index \"" + index + "\" out of range of stride"
+		}
+		keys[i] = items[n]
+	}
+	return keys, ""
 }
 
 const (
 	opGlob = iota + 1
 	opExact
 	opInteger
+	opReal
 	opRegExp
 )
 
+// Binary search sorted list, returns index to insert pattern after any equal elements.
+func lsearchBisect(tcl *Tcl, list []string, pattern string, opts sortOptions) int {
+	low := 0
+	high := len(list)
+	for low < high {
+		mid := (low + high) / 2
+		after, ret := tcl.order(opts, pattern, list[mid])
+		if ret != RetOk {
+			return tcl.SetResult(RetError, "Not a number")
+		}
+		if after {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(low, 10))
+}
+
 // Sort a list.
 func cmdLSearch(tcl *Tcl, args []string) int {
 	op := opGlob
@@ -334,6 +542,9 @@ func cmdLSearch(tcl *Tcl, args []string) int {
 	not := false
 	start := 0
 	sort := false
+	bisect := false
+	count := false
+	reverse := false
 
 	i := 1
 outer:
@@ -342,6 +553,14 @@ outer:
 		switch args[i] {
 		case "-integer":
 			op = opInteger
+		case "-real":
+			op = opReal
+		case "-ascii":
+			op = opExact
+		case "-increasing":
+			reverse = false
+		case "-decreasing":
+			reverse = true
 		case "-glob":
 			op = opGlob
 		case "-exact":
@@ -358,6 +577,10 @@ outer:
 			inline = true
 		case "-sorted":
 			sort = true
+		case "-bisect":
+			bisect = true
+		case "-count":
+			count = true
 		case "-start":
 			i++
 			if i >= len(args) {
@@ -391,7 +614,28 @@ outer:
 		}
 		matchValue = m
 	}
+	matchReal := 0.0
+	if op == opReal {
+		m, ok := formatFloat(args[i+1])
+		if !ok {
+			return tcl.SetResult(RetError, "pattern not a number")
+		}
+		matchReal = m
+	}
+
+	if op == opRegExp && ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	if bisect {
+		if strings.TrimSpace(args[i]) == "" {
+			list = nil
+		}
+		opts := sortOptions{integer: op == opInteger, real: op == opReal, nocase: ignoreCase, reverse: reverse}
+		return lsearchBisect(tcl, list, pattern, opts)
+	}
 	result := []string{}
+	counter := 0
 
 matchLoop:
 	// Scan list for values.
@@ -426,13 +670,23 @@ matchLoop:
 				return tcl.SetResult(RetError, "Not a number")
 			}
 			match = matchValue == v
+
+		case opReal:
+			v, ok := formatFloat(value)
+			if !ok {
+				return tcl.SetResult(RetError, "Not a number")
+			}
+			match = matchReal == v
 		}
 
 		// Evaluate match.
 		if not != match {
-			if inline {
+			switch {
+			case count:
+				counter++
+			case inline:
 				result = append(result, value)
-			} else {
+			default:
 				result = append(result, ConvertNumberToString(i, 10))
 			}
 			if !all {
@@ -441,6 +695,10 @@ matchLoop:
 		}
 	}
 
+	if count {
+		return tcl.SetResult(RetOk, ConvertNumberToString(counter, 10))
+	}
+
 	if len(result) == 0 {
 		return tcl.SetResult(RetOk, "-1")
 	}
@@ -453,7 +711,7 @@ matchLoop:
 			key := result[j]
 			k = j - 1
 			for k >= 0 {
-				ord, err := tcl.order(op == opInteger, false, "", key, result[k])
+				ord, err := tcl.order(sortOptions{integer: op == opInteger}, key, result[k])
 				if err != RetOk {
 					return err
 				}
@@ -570,6 +828,30 @@ func cmdSplit(tcl *Tcl, args []string) int {
 
 // Foreach command.
 func cmdForEach(tcl *Tcl, args []string) int {
+	if len(args) < 4 {
+		return tcl.SetResult(RetError, "foreach var list body")
+	}
+	if r, _ := tcl.forEachLoop(args, false); r != RetOk {
+		return tcl.SetResult(r, "")
+	}
+	return tcl.SetResult(RetOk, "")
+}
+
+// Lmap command, like foreach but collects results of body.
+func cmdLMap(tcl *Tcl, args []string) int {
+	if len(args) < 4 || len(args)%2 != 0 {
+		return tcl.SetResult(RetError, "lmap varList list ?varList list ... body")
+	}
+	r, results := tcl.forEachLoop(args, true)
+	if r != RetOk {
+		return r
+	}
+	return cmdList(tcl, append([]string{"list"}, results...))
+}
+
+// Run body of foreach or lmap for each group of list elements. Returns
+// result code and results of body if collect is set.
+func (tcl *Tcl) forEachLoop(args []string, collect bool) (int, []string) {
 	type argList struct {
 		vars  []string // variables.
 		list  []string // Values of list.
@@ -577,16 +859,16 @@ func cmdForEach(tcl *Tcl, args []string) int {
 	}
 
 	lists := []argList{}
-
-	if len(args) < 4 {
-		tcl.SetResult(RetError, "foreach var list body")
-	}
+	results := []string{}
 
 	i := 1
 	// Convert arguments up until last one to a pair of var/list pairs.
 	for (i + 1) < len(args) {
 		v := tcl.ParseArgs(args[i])
-		l := tcl.ParseArgs(args[i+1])
+		var l []string
+		if strings.TrimSpace(args[i+1]) != "" {
+			l = tcl.ParseArgs(args[i+1])
+		}
 		lists = append(lists, argList{vars: v, list: l, index: 0})
 		i += 2
 	}
@@ -618,13 +900,17 @@ outer:
 		// Run body.
 		r := tcl.eval(body, parserOptions{})
 		switch r {
-		case RetOk, RetContinue:
+		case RetOk:
+			if collect {
+				results = append(results, tcl.result)
+			}
+		case RetContinue:
 		case RetBreak:
 			break outer
 		default:
-			return tcl.SetResult(r, "")
+			return r, nil
 		}
 	}
 
-	return tcl.SetResult(RetOk, "")
+	return RetOk, results
 }
@@ -0,0 +1,458 @@
+/*
+ * TCL  namespace support.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"sort"
+	"strings"
+)
+
+// Namespace, holds variables and sub-namespaces.
+type tclNamespace struct {
+	name     string                   // Fully qualified name.
+	parent   *tclNamespace            // Enclosing namespace, nil for global.
+	children map[string]*tclNamespace // Child namespaces.
+	vars     map[string]*tclVar       // Namespace variables.
+	path     []*tclNamespace          // Namespaces searched for commands.
+	export   []string                 // Patterns of exported commands.
+}
+
+var namespaceMap = map[string]func(*Tcl, []string) int{
+	"children": namespaceChildren, // ?namespace ?pattern ?-recursive
+	"current":  namespaceCurrent,
+	"delete":   namespaceDelete, // ?namespace ...
+	"eval":     namespaceEval,   // namespace arg ?arg ...
+	"exists":   namespaceExists, // namespace
+	"export":   namespaceExport, // ?-clear ?pattern ...
+	"import":   namespaceImport, // ?-force ?pattern ...
+	"parent":   namespaceParent, // ?namespace
+	"path":     namespacePath,   // ?namespaceList
+	"which":    namespaceWhich,  // ?-command ?-variable name
+}
+
+// Create a new namespace as child of parent.
+func newNamespace(parent *tclNamespace, name string) *tclNamespace {
+	ns := &tclNamespace{parent: parent}
+	ns.children = make(map[string]*tclNamespace)
+	ns.vars = make(map[string]*tclVar)
+	switch {
+	case parent == nil:
+		ns.name = "::"
+	case parent.parent == nil:
+		ns.name = "::" + name
+	default:
+		ns.name = parent.name + "::" + name
+	}
+	if parent != nil {
+		parent.children[name] = ns
+	}
+	return ns
+}
+
+// Split a namespace name into its components.
+func splitNamespace(name string) []string {
+	res := []string{}
+	for _, part := range strings.Split(name, "::") {
+		if part != "" {
+			res = append(res, part)
+		}
+	}
+	return res
+}
+
+// Find a namespace, relative names are from current namespace.
+// If create is true, any missing namespaces are created.
+func (tcl *Tcl) findNamespace(name string, create bool) *tclNamespace {
+	ns := tcl.env.ns
+	if strings.HasPrefix(name, "::") {
+		ns = tcl.global
+	}
+	for _, part := range splitNamespace(name) {
+		child, ok := ns.children[part]
+		if !ok {
+			if !create {
+				return nil
+			}
+			child = newNamespace(ns, part)
+		}
+		ns = child
+	}
+	return ns
+}
+
+// Return name command is stored under in namespace.
+func (ns *tclNamespace) cmdKey(name string) string {
+	if ns.parent == nil {
+		return name
+	}
+	return ns.name + "::" + name
+}
+
+// Find a command, search current namespace, then namespace path, then global namespace.
+func (tcl *Tcl) findCommand(name string) (*tclCmd, bool) {
+	key, ok := tcl.commandKey(name)
+	if !ok {
+		return nil, false
+	}
+	return tcl.cmds[key], true
+}
+
+// Return name command is stored under, searched for as by findCommand.
+func (tcl *Tcl) commandKey(name string) (string, bool) {
+	// Qualified names are looked up in the named namespace.
+	if pos := strings.LastIndex(name, "::"); pos >= 0 {
+		nsName := name[:pos]
+		if nsName == "" {
+			nsName = "::"
+		}
+		// Relative names are also looked for from global namespace.
+		nsNames := []string{nsName}
+		if !strings.HasPrefix(nsName, "::") {
+			nsNames = append(nsNames, "::"+nsName)
+		}
+		for _, nsName := range nsNames {
+			if ns := tcl.findNamespace(nsName, false); ns != nil {
+				if key := ns.cmdKey(name[pos+2:]); tcl.cmds[key] != nil {
+					return key, true
+				}
+			}
+		}
+	}
+
+	if ns := tcl.env.ns; !strings.Contains(name, "::") {
+		if key := ns.cmdKey(name); tcl.cmds[key] != nil {
+			return key, true
+		}
+		for _, path := range ns.path {
+			if key := path.cmdKey(name); tcl.cmds[key] != nil {
+				return key, true
+			}
+		}
+	}
+	return name, tcl.cmds[name] != nil
+}
+
+// Namespace command.
+func cmdNamespace(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "namespace subcommand ?arg ...")
+	}
+	fn, ok := namespaceMap[args[1]]
+	if !ok {
+		return tcl.SetResult(RetError, "namespace unknown subcommand "+args[1])
+	}
+	return fn(tcl, args)
+}
+
+// Evaluate script in namespace, creating namespace if needed.
+func namespaceEval(tcl *Tcl, args []string) int {
+	if len(args) < 4 {
+		return tcl.SetResult(RetError, "namespace eval name arg ?arg ...")
+	}
+	ns := tcl.findNamespace(args[2], true)
+
+	newenv := tcl.newEnv()
+	newenv.vars = ns.vars
+	newenv.ns = ns
+	newenv.args = strings.Join(args, " ")
+	tcl.pushEnv(newenv)
+	ret := tcl.eval(strings.Join(args[3:], " "), parserOptions{})
+	tcl.popEnv()
+	return ret
+}
+
+// Return 1 if namespace exists.
+func namespaceExists(tcl *Tcl, args []string) int {
+	if len(args) != 3 {
+		return tcl.SetResult(RetError, "namespace exists name")
+	}
+	if tcl.findNamespace(args[2], false) == nil {
+		return tcl.SetResult(RetOk, "0")
+	}
+	return tcl.SetResult(RetOk, "1")
+}
+
+// Delete namespaces, with all their commands, variables and children.
+func namespaceDelete(tcl *Tcl, args []string) int {
+	for _, name := range args[2:] {
+		ns := tcl.findNamespace(name, false)
+		if ns == nil {
+			return tcl.SetResult(RetError, "namespace "+name+" not found")
+		}
+		if ns == tcl.global {
+			return tcl.SetResult(RetError, "can't delete global namespace")
+		}
+
+		// Commands of namespace and all children start with name.
+		prefix := ns.name + "::"
+		for key, cmd := range tcl.cmds {
+			if strings.HasPrefix(key, prefix) {
+				delete(tcl.cmds, key)
+				if cmd != nil {
+					tcl.cmdDeleted(key, cmd)
+				}
+			}
+		}
+		delete(ns.parent.children, ns.name[strings.LastIndex(ns.name, "::")+2:])
+	}
+	return tcl.SetResult(RetOk, "")
+}
+
+// Set or return command search path of current namespace.
+func namespacePath(tcl *Tcl, args []string) int {
+	if len(args) > 3 {
+		return tcl.SetResult(RetError, "namespace path ?namespaceList")
+	}
+
+	ns := tcl.env.ns
+	if len(args) == 2 {
+		names := []string{}
+		for _, path := range ns.path {
+			names = append(names, path.name)
+		}
+		return tcl.SetResult(RetOk, strings.Join(names, " "))
+	}
+
+	path := []*tclNamespace{}
+	for _, name := range tcl.ParseArgs(args[2]) {
+		if name == "" {
+			continue
+		}
+		pathNs := tcl.findNamespace(name, false)
+		if pathNs == nil {
+			return tcl.SetResult(RetError, "namespace "+name+" not found")
+		}
+		path = append(path, pathNs)
+	}
+	ns.path = path
+	return tcl.SetResult(RetOk, "")
+}
+
+// List children of a namespace.
+func namespaceChildren(tcl *Tcl, args []string) int {
+	recursive := false
+	params := []string{}
+	for _, arg := range args[2:] {
+		if arg == "-recursive" {
+			recursive = true
+			continue
+		}
+		params = append(params, arg)
+	}
+	if len(params) > 2 {
+		return tcl.SetResult(RetError, "namespace children ?name ?pattern ?-recursive")
+	}
+
+	ns := tcl.env.ns
+	if len(params) > 0 {
+		ns = tcl.findNamespace(params[0], false)
+		if ns == nil {
+			return tcl.SetResult(RetError, "namespace "+params[0]+" not found")
+		}
+	}
+
+	list := ns.childNames(recursive)
+	if len(params) > 1 {
+		pattern := params[1]
+		if !strings.HasPrefix(pattern, "::") {
+			pattern = strings.TrimSuffix(ns.name, "::") + "::" + pattern
+		}
+		matched := []string{}
+		for _, name := range list {
+			if globMatch(pattern, name) {
+				matched = append(matched, name)
+			}
+		}
+		list = matched
+	}
+	return tcl.SetResult(RetOk, strings.Join(list, " "))
+}
+
+// Return sorted names of children, and all descendants if recursive.
+func (ns *tclNamespace) childNames(recursive bool) []string {
+	names := []string{}
+	for name := range ns.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := []string{}
+	for _, name := range names {
+		child := ns.children[name]
+		res = append(res, child.name)
+		if recursive {
+			res = append(res, child.childNames(true)...)
+		}
+	}
+	return res
+}
+
+// Return fully qualified name of current namespace.
+func namespaceCurrent(tcl *Tcl, args []string) int {
+	if len(args) != 2 {
+		return tcl.SetResult(RetError, "namespace current")
+	}
+	return tcl.SetResult(RetOk, tcl.env.ns.name)
+}
+
+// Return fully qualified name of parent of namespace, empty for global.
+func namespaceParent(tcl *Tcl, args []string) int {
+	if len(args) > 3 {
+		return tcl.SetResult(RetError, "namespace parent ?name")
+	}
+	ns := tcl.env.ns
+	if len(args) == 3 {
+		ns = tcl.findNamespace(args[2], false)
+		if ns == nil {
+			return tcl.SetResult(RetError, "namespace "+args[2]+" not found")
+		}
+	}
+	if ns.parent == nil {
+		return tcl.SetResult(RetOk, "")
+	}
+	return tcl.SetResult(RetOk, ns.parent.name)
+}
+
+// Add patterns of commands that may be imported from current namespace.
+// With no patterns returns the current export list.
+func namespaceExport(tcl *Tcl, args []string) int {
+	ns := tcl.env.ns
+	patterns := args[2:]
+	if len(patterns) > 0 && patterns[0] == "-clear" {
+		ns.export = nil
+		patterns = patterns[1:]
+	} else if len(patterns) == 0 {
+		return tcl.SetResult(RetOk, escapeList(ns.export))
+	}
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "::") {
+			return tcl.SetResult(RetError, "invalid export pattern \""+pattern+"\": pattern can't specify a namespace")
+		}
+		ns.export = append(ns.export, pattern)
+	}
+	return tcl.SetResult(RetOk, "")
+}
+
+// Import exported commands matching qualified patterns into current namespace.
+func namespaceImport(tcl *Tcl, args []string) int {
+	force := false
+	patterns := args[2:]
+	if len(patterns) > 0 && patterns[0] == "-force" {
+		force = true
+		patterns = patterns[1:]
+	}
+
+	cur := tcl.env.ns
+	for _, pattern := range patterns {
+		pos := strings.LastIndex(pattern, "::")
+		if pos < 0 {
+			return tcl.SetResult(RetError, "import pattern \""+pattern+"\" must be qualified")
+		}
+		nsName := pattern[:pos]
+		if nsName == "" {
+			nsName = "::"
+		}
+		ns := tcl.findNamespace(nsName, false)
+		if ns == nil {
+			return tcl.SetResult(RetError, "namespace "+nsName+" not found")
+		}
+
+		for _, name := range tcl.namespaceCommands(ns) {
+			if !globMatch(pattern[pos+2:], name) || !ns.exported(name) {
+				continue
+			}
+			key := cur.cmdKey(name)
+			if _, ok := tcl.cmds[key]; ok && !force {
+				return tcl.SetResult(RetError, "can't import command \""+name+"\": already exists")
+			}
+			tcl.cmds[key] = tcl.cmds[ns.cmdKey(name)]
+		}
+	}
+	return tcl.SetResult(RetOk, "")
+}
+
+// Return sorted names of commands defined in namespace.
+func (tcl *Tcl) namespaceCommands(ns *tclNamespace) []string {
+	prefix := ns.cmdKey("")
+	names := []string{}
+	for key := range tcl.cmds {
+		name, ok := strings.CutPrefix(key, prefix)
+		if ok && !strings.Contains(name, "::") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Return true if command name matches an export pattern of namespace.
+func (ns *tclNamespace) exported(name string) bool {
+	for _, pattern := range ns.export {
+		if globMatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return fully qualified name of command or variable, empty if not found.
+func namespaceWhich(tcl *Tcl, args []string) int {
+	usage := "namespace which ?-command ?-variable name"
+	variable := false
+	switch {
+	case len(args) == 4 && args[2] == "-variable":
+		variable = true
+	case len(args) == 4 && args[2] == "-command":
+	case len(args) != 3:
+		return tcl.SetResult(RetError, usage)
+	}
+	name := args[len(args)-1]
+
+	// Variables are looked for in current namespace then global namespace.
+	if variable {
+		ns := tcl.env.ns
+		base := name
+		if pos := strings.LastIndex(name, "::"); pos >= 0 {
+			ns = tcl.findNamespace(name[:pos]+"::", false)
+			base = name[pos+2:]
+		} else if ns.vars[name] == nil {
+			ns = tcl.global
+		}
+		if ns == nil || ns.vars[base] == nil {
+			return tcl.SetResult(RetOk, "")
+		}
+		return tcl.SetResult(RetOk, strings.TrimSuffix(ns.name, "::")+"::"+base)
+	}
+
+	key, ok := tcl.commandKey(name)
+	if !ok {
+		return tcl.SetResult(RetOk, "")
+	}
+	if !strings.HasPrefix(key, "::") {
+		key = "::" + key
+	}
+	return tcl.SetResult(RetOk, key)
+}
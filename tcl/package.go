@@ -0,0 +1,232 @@
+/*
+ * TCL  Package command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Packages that can be loaded, and those that have been.
+type packageList struct {
+	ifneeded map[string]map[string]string // Script to load each version of package.
+	provided map[string]string            // Version of loaded packages.
+}
+
+var packageMap = map[string]func(*Tcl, []string) int{
+	"ifneeded": packageIfNeeded, // name version ?script
+	"provide":  packageProvide,  // name ?version
+	"require":  packageRequire,  // ?-exact name ?version
+	"versions": packageVersions, // name
+}
+
+func newPackageList() *packageList {
+	return &packageList{ifneeded: make(map[string]map[string]string), provided: make(map[string]string)}
+}
+
+// Check that version is numbers separated by dots.
+func validVersion(version string) bool {
+	for _, part := range strings.Split(version, ".") {
+		if part == "" {
+			return false
+		}
+		for _, ch := range part {
+			if !unicode.IsDigit(ch) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Compare dotted versions, returns -1, 0 or 1. Missing parts count as 0.
+func compareVersions(a string, b string) int {
+	aparts := strings.Split(a, ".")
+	bparts := strings.Split(b, ".")
+	for i := 0; i < len(aparts) || i < len(bparts); i++ {
+		av, bv := 0, 0
+		if i < len(aparts) {
+			av, _, _ = ConvertStringToNumber(aparts[i], 10, 0)
+		}
+		if i < len(bparts) {
+			bv, _, _ = ConvertStringToNumber(bparts[i], 10, 0)
+		}
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Check if version satisfies requested version. Without exact, version must
+// have same major number and be at least the requested version.
+func versionSatisfies(version string, request string, exact bool) bool {
+	if request == "" {
+		return true
+	}
+	if exact {
+		return compareVersions(version, request) == 0
+	}
+	major := strings.SplitN(version, ".", 2)[0]
+	if compareVersions(major, strings.SplitN(request, ".", 2)[0]) != 0 {
+		return false
+	}
+	return compareVersions(version, request) >= 0
+}
+
+// Package command.
+func cmdPackage(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "package subcommand ?arg ...")
+	}
+	fn, ok := packageMap[args[1]]
+	if !ok {
+		return tcl.SetResult(RetError, "package unknown subcommand "+args[1])
+	}
+	return fn(tcl, args)
+}
+
+// Set or return script to load version of package.
+func packageIfNeeded(tcl *Tcl, args []string) int {
+	if len(args) != 4 && len(args) != 5 {
+		return tcl.SetResult(RetError, "package ifneeded name version ?script")
+	}
+	name, version := args[2], args[3]
+	if !validVersion(version) {
+		return tcl.SetResult(RetError, "expected version number but got \""+version+"\"")
+	}
+	if len(args) == 4 {
+		return tcl.SetResult(RetOk, tcl.packages.ifneeded[name][version])
+	}
+	if tcl.packages.ifneeded[name] == nil {
+		tcl.packages.ifneeded[name] = make(map[string]string)
+	}
+	tcl.packages.ifneeded[name][version] = args[4]
+	return tcl.SetResult(RetOk, "")
+}
+
+// Record package as loaded, or return version loaded.
+func packageProvide(tcl *Tcl, args []string) int {
+	if len(args) != 3 && len(args) != 4 {
+		return tcl.SetResult(RetError, "package provide name ?version")
+	}
+	name := args[2]
+	if len(args) == 3 {
+		return tcl.SetResult(RetOk, tcl.packages.provided[name])
+	}
+	version := args[3]
+	if !validVersion(version) {
+		return tcl.SetResult(RetError, "expected version number but got \""+version+"\"")
+	}
+	if loaded, ok := tcl.packages.provided[name]; ok && loaded != version {
+		return tcl.SetResult(RetError, "conflicting versions provided for package \""+name+"\": "+loaded+", then "+version)
+	}
+	tcl.packages.provided[name] = version
+	return tcl.SetResult(RetOk, "")
+}
+
+// Return highest version of package that satisfies request, or empty string.
+func (list *packageList) best(name string, request string, exact bool) string {
+	best := ""
+	for version := range list.ifneeded[name] {
+		if versionSatisfies(version, request, exact) && (best == "" || compareVersions(version, best) > 0) {
+			best = version
+		}
+	}
+	return best
+}
+
+// Load best version of package that satisfies request, returns version loaded.
+func packageRequire(tcl *Tcl, args []string) int {
+	usage := "package require ?-exact name ?version"
+	exact := false
+	i := 2
+	if i < len(args) && args[i] == "-exact" {
+		exact = true
+		i++
+	}
+	if i >= len(args) || i+2 < len(args) || (exact && i+2 != len(args)) {
+		return tcl.SetResult(RetError, usage)
+	}
+	name := args[i]
+	request := ""
+	if i+1 < len(args) {
+		request = args[i+1]
+		if !validVersion(request) {
+			return tcl.SetResult(RetError, "expected version number but got \""+request+"\"")
+		}
+	}
+
+	// Already loaded.
+	if loaded, ok := tcl.packages.provided[name]; ok {
+		if !versionSatisfies(loaded, request, exact) {
+			return tcl.SetResult(RetError, "version conflict for package \""+name+"\": have "+loaded+", need "+request)
+		}
+		return tcl.SetResult(RetOk, loaded)
+	}
+
+	// Unknown packages are looked for in index files of auto_path.
+	best := tcl.packages.best(name, request, exact)
+	if best == "" {
+		if ret := tcl.loadPackageIndexes(); ret == RetError {
+			return ret
+		}
+		best = tcl.packages.best(name, request, exact)
+	}
+	if best == "" {
+		if request != "" {
+			return tcl.SetResult(RetError, "can't find package "+name+" "+request)
+		}
+		return tcl.SetResult(RetError, "can't find package "+name)
+	}
+
+	if ret := tcl.evalGlobal(tcl.packages.ifneeded[name][best]); ret == RetError {
+		return ret
+	}
+
+	// Script may not provide package itself.
+	if _, ok := tcl.packages.provided[name]; !ok {
+		tcl.packages.provided[name] = best
+	}
+	return tcl.SetResult(RetOk, tcl.packages.provided[name])
+}
+
+// Return versions of package that can be loaded.
+func packageVersions(tcl *Tcl, args []string) int {
+	if len(args) != 3 {
+		return tcl.SetResult(RetError, "package versions name")
+	}
+	versions := []string{}
+	for version := range tcl.packages.ifneeded[args[2]] {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return compareVersions(versions[i], versions[j]) < 0 })
+	return tcl.SetResult(RetOk, strings.Join(versions, " "))
+}
@@ -68,7 +68,7 @@ func newParser(str string, options parserOptions) *parser {
 
 // Collect next token.
 func (p *parser) getToken() bool {
-	for p.char != 0 {
+	for !p.atEnd() {
 		switch p.char {
 		case ' ', '\t':
 			if p.inQuote {
@@ -151,6 +151,11 @@ func (p *parser) next() {
 	}
 }
 
+// Check if at end of string, null characters in string are not the end.
+func (p *parser) atEnd() bool {
+	return p.pos >= len(p.str)
+}
+
 // Check if space character.
 func (p *parser) parseIsSpace() bool {
 	return p.char == ' ' || p.char == '\t' || p.char == '\n' || p.char == '\r'
@@ -185,7 +190,7 @@ func (p *parser) parseCommand() bool {
 	braceLevel := 0
 	level := 1
 outer:
-	for p.char != 0 {
+	for !p.atEnd() {
 		switch p.char {
 		case '[':
 			if braceLevel == 0 {
@@ -210,8 +215,6 @@ outer:
 			if braceLevel != 0 {
 				braceLevel--
 			}
-		case 0:
-			break outer
 		}
 		p.next()
 	}
@@ -242,21 +245,46 @@ func (p *parser) parseVar() bool {
 	}
 
 	p.start = p.pos // Grab all variable characters.
-	for p.isVarChar() {
+	for {
+		if brace && p.char == ':' {
+			p.next()
+			continue
+		}
+		if p.char == ':' && p.nextPos < len(p.str) && p.str[p.nextPos] == ':' {
+			for p.char == ':' { // Namespace separator.
+				p.next()
+			}
+			continue
+		}
+		if !p.isVarChar() {
+			break
+		}
 		p.next()
 	}
-	p.end = p.pos
 
-	if p.start == p.end { // $ does not have following name.
+	if p.pos == p.start { // $ does not have following name.
 		p.start = startPos
+		p.end = p.pos
 		p.token = tokString
 		return true
 	}
 
-	if brace { // If brace, make sure trailing brace.
+	if p.char == '(' && !brace { // Array index, include up to ).
+		for p.char != ')' {
+			if p.atEnd() {
+				return false
+			}
+			p.next()
+		}
+		p.next()
+	}
+	p.end = p.pos
+
+	if brace { // If brace, make sure trailing brace and skip it.
 		if p.char != '}' {
 			return false
 		}
+		p.next()
 	}
 	p.token = tokVar
 	return true
@@ -267,7 +295,7 @@ func (p *parser) parseBrace() bool {
 	p.next()
 	p.start = p.pos
 	level := 1
-	for {
+	for !p.atEnd() {
 		switch p.char {
 		case '\\':
 			if p.nextPos < len(p.str) { // Must have at least 2 characters.
@@ -288,11 +316,10 @@ func (p *parser) parseBrace() bool {
 		case '{':
 			// Increase nesting level.
 			level++
-		case 0:
-			return false
 		}
 		p.next()
 	}
+	return false
 }
 
 // Collect the following string.
@@ -312,7 +339,7 @@ func (p *parser) parseString() bool {
 
 	p.start = p.pos
 	// Scan until end of string.
-	for p.char != 0 {
+	for !p.atEnd() {
 		switch p.char {
 		case '\\':
 			// If not escaping, make sure at least 2 characters remain.
@@ -340,7 +367,7 @@ func (p *parser) parseString() bool {
 				return true
 			}
 
-		case ' ', '\t', ';', '\n', 0:
+		case ' ', '\t', ';', '\n':
 			// Blanks, if not in quoted string, return what we got.
 			if !p.inQuote {
 				p.end = p.pos
@@ -374,7 +401,7 @@ func (p *parser) parseString() bool {
 
 // Skip rest of line.
 func (p *parser) parseComment() bool {
-	for p.char != '\n' && p.char != 0 {
+	for p.char != '\n' && !p.atEnd() {
 		if p.char == '\\' && p.str[p.pos+1] == '\n' { // skip \ eol
 			p.next()
 		}
@@ -0,0 +1,279 @@
+/*
+ * TCL  regular expression commands.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Match a regular expression against a string.
+func cmdRegexp(tcl *Tcl, args []string) int {
+	usage := "regexp ?-nocase ?-all ?-inline ?-indices ?-line ?-start index pattern string ?matchVar ?subMatchVar ..."
+	nocase := false
+	all := false
+	inline := false
+	indices := false
+	line := false
+	start := 0
+	i := 1
+outer:
+	for ; i < len(args); i++ {
+		switch args[i] {
+		case "-nocase":
+			nocase = true
+		case "-all":
+			all = true
+		case "-inline":
+			inline = true
+		case "-indices":
+			indices = true
+		case "-line":
+			line = true
+		case "-start":
+			i++
+			if i >= len(args) {
+				return tcl.SetResult(RetError, "missing argument for start")
+			}
+			s, _, ok := ConvertStringToNumber(args[i], 10, 0)
+			if !ok {
+				return tcl.SetResult(RetError, "start option not a number")
+			}
+			start = max(s, 0)
+		case "--":
+			i++
+			break outer
+		default:
+			break outer
+		}
+	}
+
+	if (i + 2) > len(args) {
+		return tcl.SetResult(RetError, usage)
+	}
+	vars := args[i+2:]
+	if inline && len(vars) != 0 {
+		return tcl.SetResult(RetError, "regexp match variables not allowed when using -inline")
+	}
+
+	pattern := args[i]
+	if line {
+		pattern = "(?m)" + pattern
+	}
+	if nocase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return tcl.SetResult(RetError, "couldn't compile regular expression pattern: "+err.Error())
+	}
+
+	// Matching begins at start, past end of string nothing matches.
+	str := args[i+1]
+	if start > len(str) {
+		return tcl.SetResult(RetOk, "0")
+	}
+	var found [][]int
+	if all {
+		found = re.FindAllStringSubmatchIndex(str[start:], -1)
+	} else if loc := re.FindStringSubmatchIndex(str[start:]); loc != nil {
+		found = [][]int{loc}
+	}
+	matches := make([][]string, len(found))
+	for m, loc := range found {
+		matches[m] = regexpMatches(str, loc, start, indices)
+	}
+
+	if inline {
+		res := []string{}
+		for _, match := range matches {
+			if len(match) == 1 {
+				res = append(res, StringEscape(match[0]))
+			} else if all {
+				res = append(res, StringEscape(escapeList(match)))
+			} else {
+				res = append(res, escapeList(match))
+			}
+		}
+		return tcl.SetResult(RetOk, strings.Join(res, " "))
+	}
+
+	// Variables are set from last match.
+	if len(matches) != 0 {
+		match := matches[len(matches)-1]
+		for v, name := range vars {
+			value := ""
+			if v < len(match) {
+				value = match[v]
+			}
+			if ret, msg := tcl.setVar(name, value); ret != RetOk {
+				return tcl.SetResult(ret, msg)
+			}
+		}
+	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(len(matches), 10))
+}
+
+// Convert locations of match and groups to strings, or to lists of first and
+// last index into str. Groups that did not match are empty or -1 -1.
+func regexpMatches(str string, loc []int, start int, indices bool) []string {
+	match := make([]string, len(loc)/2)
+	for g := range match {
+		first, last := loc[2*g], loc[2*g+1]
+		switch {
+		case indices && first < 0:
+			match[g] = "-1 -1"
+		case indices:
+			match[g] = ConvertNumberToString(start+first, 10) + " " + ConvertNumberToString(start+last-1, 10)
+		case first >= 0:
+			match[g] = str[start+first : start+last]
+		}
+	}
+	return match
+}
+
+// Build a list from strings.
+func escapeList(list []string) string {
+	res := make([]string, len(list))
+	for i, item := range list {
+		res[i] = StringEscape(item)
+	}
+	return strings.Join(res, " ")
+}
+
+// Substitute matches of a regular expression in a string.
+func cmdRegsub(tcl *Tcl, args []string) int {
+	usage := "regsub ?-nocase ?-all ?-line ?-start index pattern string replacement ?varName"
+	nocase := false
+	all := false
+	line := false
+	start := 0
+	i := 1
+outer:
+	for ; i < len(args); i++ {
+		switch args[i] {
+		case "-nocase":
+			nocase = true
+		case "-all":
+			all = true
+		case "-line":
+			line = true
+		case "-start":
+			i++
+			if i >= len(args) {
+				return tcl.SetResult(RetError, "missing argument for start")
+			}
+			s, _, ok := ConvertStringToNumber(args[i], 10, 0)
+			if !ok {
+				return tcl.SetResult(RetError, "start option not a number")
+			}
+			start = max(s, 0)
+		case "--":
+			i++
+			break outer
+		default:
+			break outer
+		}
+	}
+
+	if (i+3) != len(args) && (i+4) != len(args) {
+		return tcl.SetResult(RetError, usage)
+	}
+
+	pattern := args[i]
+	if line {
+		pattern = "(?m)" + pattern
+	}
+	if nocase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return tcl.SetResult(RetError, "couldn't compile regular expression pattern: "+err.Error())
+	}
+
+	// Text before start is copied unchanged.
+	str := args[i+1]
+	start = min(start, len(str))
+	count := 1
+	if all {
+		count = -1
+	}
+	matches := re.FindAllStringSubmatchIndex(str[start:], count)
+
+	// Build result from text between matches and expanded replacement.
+	result := str[:start]
+	str = str[start:]
+	last := 0
+	for _, match := range matches {
+		result += str[last:match[0]] + expandReplacement(str, args[i+2], match)
+		last = match[1]
+	}
+	result += str[last:]
+
+	if (i + 4) == len(args) {
+		if ret, msg := tcl.setVar(args[i+3], result); ret != RetOk {
+			return tcl.SetResult(ret, msg)
+		}
+		return tcl.SetResult(RetOk, ConvertNumberToString(len(matches), 10))
+	}
+	return tcl.SetResult(RetOk, result)
+}
+
+// Expand replacement string, & and \0 are replaced by match, \1 to \9 by
+// capture groups, and \\ by a backslash.
+func expandReplacement(str string, replacement string, match []int) string {
+	group := func(n int) string {
+		if 2*n+1 >= len(match) || match[2*n] < 0 {
+			return ""
+		}
+		return str[match[2*n]:match[2*n+1]]
+	}
+
+	result := ""
+	for pos := 0; pos < len(replacement); pos++ {
+		ch := replacement[pos]
+		switch {
+		case ch == '&':
+			result += group(0)
+		case ch == '\\' && pos+1 < len(replacement):
+			next := replacement[pos+1]
+			switch {
+			case next >= '0' && next <= '9':
+				result += group(int(next - '0'))
+				pos++
+			case next == '\\' || next == '&':
+				result += string(next)
+				pos++
+			default:
+				result += string(ch)
+			}
+		default:
+			result += string(ch)
+		}
+	}
+	return result
+}
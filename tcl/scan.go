@@ -0,0 +1,320 @@
+/*
+ * TCL  scan command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parse a string using conversion specifiers, like the C sscanf function.
+func cmdScan(tcl *Tcl, args []string) int {
+	if len(args) < 3 {
+		return tcl.SetResult(RetError, "scan string format ?varName ...")
+	}
+	values, count, err := scanString(args[1], args[2])
+	if err != "" {
+		return tcl.SetResult(RetError, err)
+	}
+	if count < 0 {
+		return tcl.SetResult(RetOk, "-1")
+	}
+
+	// With no variables return the values as a list, empty for conversions
+	// that were not done.
+	names := args[3:]
+	if len(names) == 0 {
+		return tcl.SetResult(RetOk, escapeList(values))
+	}
+
+	for i, name := range names {
+		if i >= len(values) || values[i] == "" {
+			break
+		}
+		if ret, msg := tcl.setVar(name, values[i]); ret != RetOk {
+			return tcl.SetResult(ret, msg)
+		}
+	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(min(count, len(names)), 10))
+}
+
+// Scan input according to format, returns a value for each conversion, the
+// number of conversions done or -1 if input ran out before the first one, or
+// error message. Scanning stops at the first conversion that fails, the values
+// of it and the remaining conversions are empty.
+func scanString(input string, format string) ([]string, int, string) {
+	values := []string{}
+	count := 0
+	stopped := false
+	pos := 0
+	for i := 0; i < len(format); i++ {
+		ch := format[i]
+		if isSpace(ch) {
+			pos = skipSpace(input, pos)
+			continue
+		}
+		if ch != '%' || (i+1 < len(format) && format[i+1] == '%') {
+			if ch == '%' {
+				i++
+			}
+			if stopped {
+				continue
+			}
+			if pos >= len(input) || input[pos] != ch {
+				if pos >= len(input) && count == 0 {
+					count = -1
+				}
+				stopped = true
+				continue
+			}
+			pos++
+			continue
+		}
+
+		// Collect suppression, width and size modifiers.
+		i++
+		suppress := false
+		if i < len(format) && format[i] == '*' {
+			suppress = true
+			i++
+		}
+		width := 0
+		for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+			width = width*10 + int(format[i]-'0')
+			i++
+		}
+		for i < len(format) && (format[i] == 'l' || format[i] == 'h' || format[i] == 'L') {
+			i++
+		}
+		if i >= len(format) {
+			return nil, 0, "format string ended in middle of field specifier"
+		}
+
+		conv := format[i]
+		set := ""
+		if conv == '[' {
+			end := scanSetEnd(format, i+1)
+			if end < 0 {
+				return nil, 0, "unmatched [ in format string"
+			}
+			set = format[i+1 : end]
+			i = end
+		}
+		if !strings.ContainsRune("dioxXfegcs[n", rune(conv)) {
+			return nil, 0, "bad scan conversion character \"" + string(conv) + "\""
+		}
+		if stopped {
+			if !suppress {
+				values = append(values, "")
+			}
+			continue
+		}
+		if conv != 'c' && conv != '[' && conv != 'n' {
+			pos = skipSpace(input, pos)
+		}
+		if conv != 'n' && pos >= len(input) {
+			if count == 0 {
+				count = -1
+			}
+			stopped = true
+			if !suppress {
+				values = append(values, "")
+			}
+			continue
+		}
+
+		// Limit input to field width.
+		field := input[pos:]
+		if width > 0 && width < len(field) {
+			field = field[:width]
+		}
+
+		value := ""
+		size := 0
+		switch conv {
+		case 'd', 'i', 'o', 'x', 'X':
+			value, size = scanInteger(field, conv)
+		case 'f', 'e', 'g':
+			value, size = scanFloat(field)
+		case 'c':
+			r, n := utf8.DecodeRuneInString(field)
+			value, size = ConvertNumberToString(int(r), 10), n
+		case 's':
+			for size < len(field) && !isSpace(field[size]) {
+				size++
+			}
+			value = field[:size]
+		case '[':
+			for size < len(field) {
+				r, n := utf8.DecodeRuneInString(field[size:])
+				if !scanSetMatch(set, r) {
+					break
+				}
+				size += n
+			}
+			value = field[:size]
+		case 'n':
+			value, size = ConvertNumberToString(pos, 10), -1
+		}
+		if size == 0 {
+			stopped = true
+			value = ""
+		}
+		if size > 0 {
+			pos += size
+		}
+		if !suppress {
+			values = append(values, value)
+			if !stopped {
+				count++
+			}
+		}
+	}
+	return values, count, ""
+}
+
+// Check for white space.
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\v' || ch == '\f'
+}
+
+// Skip over white space in string.
+func skipSpace(str string, pos int) int {
+	for pos < len(str) && isSpace(str[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// Scan an integer at start of field, returns decimal value and size used.
+func scanInteger(field string, conv byte) (string, int) {
+	pos := 0
+	if pos < len(field) && (field[pos] == '-' || field[pos] == '+') {
+		pos++
+	}
+	base := 10
+	digits := pos
+	switch conv {
+	case 'o':
+		base = 8
+	case 'x', 'X':
+		base = 16
+		if strings.HasPrefix(strings.ToLower(field[pos:]), "0x") && len(field) > pos+2 {
+			digits = pos + 2
+		}
+	case 'i':
+		lower := strings.ToLower(field[pos:])
+		switch {
+		case strings.HasPrefix(lower, "0x") && len(field) > pos+2:
+			base = 16
+			digits = pos + 2
+		case strings.HasPrefix(lower, "0"):
+			base = 8
+		}
+	}
+	end := digits
+	for end < len(field) && isDigit(field[end], base) {
+		end++
+	}
+	if end == digits {
+		return "", 0
+	}
+	num, err := strconv.ParseInt(field[digits:end], base, 64)
+	if err != nil {
+		return "", 0
+	}
+	if field[0] == '-' {
+		num = -num
+	}
+	return strconv.FormatInt(num, 10), end
+}
+
+// Scan a floating point number at start of field, returns value and size used.
+func scanFloat(field string) (string, int) {
+	if num, size, ok := ConvertStringToFloat(field, 0); ok {
+		return ConvertFloatToString(num), size
+	}
+	value, size := scanInteger(field, 'd')
+	if size == 0 {
+		return "", 0
+	}
+	num, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", 0
+	}
+	return ConvertFloatToString(num), size
+}
+
+// Check if character is a digit in base.
+func isDigit(ch byte, base int) bool {
+	switch {
+	case ch >= '0' && ch <= '9':
+		return int(ch-'0') < base
+	case base == 16:
+		return strings.IndexByte("abcdefABCDEF", ch) >= 0
+	}
+	return false
+}
+
+// Find closing bracket of a character set, returns -1 if none.
+func scanSetEnd(format string, pos int) int {
+	if pos < len(format) && format[pos] == '^' {
+		pos++
+	}
+	if pos < len(format) && format[pos] == ']' {
+		pos++
+	}
+	end := strings.IndexByte(format[pos:], ']')
+	if end < 0 {
+		return -1
+	}
+	return pos + end
+}
+
+// Check if character is matched by a character set.
+func scanSetMatch(set string, ch rune) bool {
+	negate := false
+	if strings.HasPrefix(set, "^") {
+		negate = true
+		set = set[1:]
+	}
+	chars := []rune(set)
+	match := false
+	for i := 0; i < len(chars); i++ {
+		if i+2 < len(chars) && chars[i+1] == '-' {
+			if ch >= chars[i] && ch <= chars[i+2] {
+				match = true
+			}
+			i += 2
+			continue
+		}
+		if chars[i] == ch {
+			match = true
+		}
+	}
+	return match != negate
+}
@@ -26,29 +26,43 @@
 package tcl
 
 import (
+	"cmp"
+	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
+)
+
+// Patterns for string is classes that match whole string.
+var (
+	uuidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	emailPattern = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9\-]+(\.[a-zA-Z0-9\-]+)*\.[a-zA-Z]{2,}$`)
 )
 
 var funcMap = map[string]func(*Tcl, []string) int{
-	"compare":   stringCompare, // -nocase, -length int, string1, string2
-	"equal":     stringCompare, // -nocase, -length int, string1, string2
-	"first":     stringFind,    // needleString hayStack startIndex
-	"last":      stringFind,    // needleString hayStack lastIndex
-	"index":     stringIndex,   // string index
-	"is":        stringIs,
-	"length":    stringLength,
-	"map":       stringMap,     // -nocase mapping string
-	"match":     stringMatch,   // -nocase pattern string
-	"range":     stringRange,   // string first last
-	"repeat":    stringRepeat,  // string count
-	"replace":   stringReplace, // string first last ?newstring
-	"tolower":   stringToCase,  // string ?first ??last
-	"totitle":   stringToCase,  // string ?first ??last
-	"toupper":   stringToCase,  // string ?first ??last
-	"trim":      stringTrim,    // string ?chars
-	"trimleft":  stringTrim,    // string ?chars
-	"trimright": stringTrim,    // string ?chars
+	"bytelength": stringByteLength, // string
+	"byterange":  stringByteRange,  // string first last
+	"cat":        stringCat,        // ?string ...
+	"compare":    stringCompare,    // -nocase, -length int, string1, string2
+	"equal":      stringCompare,    // -nocase, -length int, string1, string2
+	"first":      stringFind,       // needleString hayStack startIndex
+	"format":     stringFormat,     // formatString ?arg ...
+	"last":       stringFind,       // needleString hayStack lastIndex
+	"index":      stringIndex,      // string index
+	"is":         stringIs,
+	"length":     stringLength,
+	"map":        stringMap,     // -nocase mapping string
+	"match":      stringMatch,   // -nocase pattern string
+	"range":      stringRange,   // string first last
+	"repeat":     stringRepeat,  // string count
+	"replace":    stringReplace, // string first last ?newstring
+	"tolower":    stringToCase,  // string ?first ??last
+	"totitle":    stringToCase,  // string ?first ??last
+	"toupper":    stringToCase,  // string ?first ??last
+	"trim":       stringTrim,    // string ?chars
+	"trimleft":   stringTrim,    // string ?chars
+	"trimright":  stringTrim,    // string ?chars
 }
 
 func cmdString(tcl *Tcl, args []string) int {
@@ -66,6 +80,7 @@ func cmdString(tcl *Tcl, args []string) int {
 func stringCompare(tcl *Tcl, args []string) int {
 	equal := false
 	nocase := false
+	dictionary := false
 	length := -1
 
 	if args[1] == "equal" {
@@ -78,6 +93,8 @@ outer:
 		switch args[i] {
 		case "-nocase":
 			nocase = true
+		case "-dictionary":
+			dictionary = true
 		case "-length":
 			i++
 			if i >= len(args) {
@@ -118,7 +135,12 @@ outer:
 		str2 = strings.ToLower(str2)
 	}
 
-	res := strings.Compare(str1, str2)
+	var res int
+	if dictionary {
+		res = dictionaryCompare(str1, str2)
+	} else {
+		res = strings.Compare(str1, str2)
+	}
 	if equal {
 		if res == 0 {
 			res = 1
@@ -130,6 +152,45 @@ outer:
 	return tcl.SetResult(RetOk, ConvertNumberToString(res, 10))
 }
 
+// Compare two strings treating runs of digits as integers, so that
+// file9 sorts before file10.
+func dictionaryCompare(str1, str2 string) int {
+	i, j := 0, 0
+	for i < len(str1) && j < len(str2) {
+		if isDigit(str1[i], 10) && isDigit(str2[j], 10) {
+			// Find end of each run of digits.
+			ei, ej := i, j
+			for ei < len(str1) && isDigit(str1[ei], 10) {
+				ei++
+			}
+			for ej < len(str2) && isDigit(str2[ej], 10) {
+				ej++
+			}
+
+			// Drop leading zeros, then longer number is bigger.
+			num1 := strings.TrimLeft(str1[i:ei], "0")
+			num2 := strings.TrimLeft(str2[j:ej], "0")
+			if res := cmp.Compare(len(num1), len(num2)); res != 0 {
+				return res
+			}
+			if res := strings.Compare(num1, num2); res != 0 {
+				return res
+			}
+			i, j = ei, ej
+			continue
+		}
+
+		r1, s1 := utf8.DecodeRuneInString(str1[i:])
+		r2, s2 := utf8.DecodeRuneInString(str2[j:])
+		if res := cmp.Compare(r1, r2); res != 0 {
+			return res
+		}
+		i += s1
+		j += s2
+	}
+	return cmp.Compare(len(str1)-i, len(str2)-j)
+}
+
 // Find character in string.
 func stringFind(tcl *Tcl, args []string) int {
 	if len(args) > 5 {
@@ -169,7 +230,7 @@ func stringIndex(tcl *Tcl, args []string) int {
 	if len(args) > 4 {
 		return tcl.SetResult(RetError, "string index string index")
 	}
-	str := args[2]
+	str := []rune(args[2])
 	index, _, ok := convertListIndex(args[3], len(str), 0)
 	if !ok {
 		return tcl.SetResult(RetError, "index invalid")
@@ -195,13 +256,21 @@ first:
 		case "-strict":
 			strict = true
 		case "-failindex":
-			fail = args[i+1]
 			i++
+			if i >= len(args) {
+				return tcl.SetResult(RetError, "missing failindex variable")
+			}
+			fail = args[i]
 		default:
 			break first
 		}
 	}
 
+	if i != len(args)-1 {
+		return tcl.SetResult(RetError, "string is class ?-strict ?-failindex varname? string")
+	}
+
+	// Empty string matches any class unless strict.
 	if len(args[i]) == 0 {
 		if strict {
 			return tcl.SetResult(RetOk, "0")
@@ -253,6 +322,21 @@ outer:
 				break outer
 			}
 
+		case "double", "entier", "integer":
+			valid, pos := isNumber(args[i], class == "double")
+			if valid {
+				return tcl.SetResult(RetOk, "1")
+			}
+			index = pos
+			ok = false
+			break outer
+
+		case "email":
+			if emailPattern.MatchString(args[i]) {
+				return tcl.SetResult(RetOk, "1")
+			}
+			return tcl.SetResult(RetOk, "0")
+
 		case "false":
 			v, tok := truthValue[args[i]]
 			if tok && !v {
@@ -297,11 +381,30 @@ outer:
 			}
 			return tcl.SetResult(RetOk, "0")
 
+		case "wideinteger":
+			valid, pos := isNumber(args[i], false)
+			if valid {
+				if _, err := strconv.ParseInt(strings.TrimSpace(args[i]), 0, 64); err == nil {
+					return tcl.SetResult(RetOk, "1")
+				}
+				// Out of range has no invalid character.
+				pos = -1
+			}
+			index = pos
+			ok = false
+			break outer
+
 		case "upper":
 			if !unicode.IsUpper(ch) {
 				ok = false
 				break outer
 			}
+
+		case "uuid":
+			if uuidPattern.MatchString(args[i]) {
+				return tcl.SetResult(RetOk, "1")
+			}
+			return tcl.SetResult(RetOk, "0")
 		}
 	}
 
@@ -315,11 +418,61 @@ outer:
 	return tcl.SetResult(RetOk, "1")
 }
 
+// Check if whole string is a number, surrounding white space is allowed.
+// Returns false and position of first invalid character if not.
+func isNumber(str string, float bool) (bool, int) {
+	trimmed := strings.TrimSpace(str)
+	if float {
+		if _, err := strconv.ParseFloat(trimmed, 64); err == nil {
+			return true, 0
+		}
+	}
+	_, pos, ok := ConvertStringToNumber(str, 10, 0)
+	if float {
+		if _, fpos, fok := ConvertStringToFloat(str, 0); fok {
+			pos, ok = fpos, true
+		}
+	}
+	if !ok {
+		return false, len(str) - len(strings.TrimLeftFunc(str, unicode.IsSpace))
+	}
+	if strings.TrimSpace(str[pos:]) != "" {
+		return false, pos
+	}
+	return true, 0
+}
+
+// Concatenate strings.
+func stringCat(tcl *Tcl, args []string) int {
+	// A single string needs no copy.
+	if len(args) == 3 {
+		return tcl.SetResult(RetOk, args[2])
+	}
+	size := 0
+	for _, arg := range args[2:] {
+		size += len(arg)
+	}
+	var str strings.Builder
+	str.Grow(size)
+	for _, arg := range args[2:] {
+		str.WriteString(arg)
+	}
+	return tcl.SetResult(RetOk, str.String())
+}
+
 // Return length of string.
 func stringLength(tcl *Tcl, args []string) int {
-	if len(args) > 3 {
+	if len(args) != 3 {
 		return tcl.SetResult(RetError, "string length string")
 	}
+	return tcl.SetResult(RetOk, ConvertNumberToString(utf8.RuneCountInString(args[2]), 10))
+}
+
+// Return length of string in bytes.
+func stringByteLength(tcl *Tcl, args []string) int {
+	if len(args) != 3 {
+		return tcl.SetResult(RetError, "string bytelength string")
+	}
 	return tcl.SetResult(RetOk, ConvertNumberToString(len(args[2]), 10))
 }
 
@@ -394,35 +547,59 @@ func stringMatch(tcl *Tcl, args []string) int {
 
 // Return characters between first and last index.
 func stringRange(tcl *Tcl, args []string) int {
-	if len(args) > 5 {
+	if len(args) != 5 {
 		return tcl.SetResult(RetError, "string range string first last")
 	}
+	str := []rune(args[2])
+	first, last, msg := rangeIndices(args[3], args[4], len(str))
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+	return tcl.SetResult(RetOk, string(str[first:last]))
+}
+
+// Return range of bytes from string.
+func stringByteRange(tcl *Tcl, args []string) int {
+	if len(args) != 5 {
+		return tcl.SetResult(RetError, "string byterange string first last")
+	}
 	str := args[2]
-	first, _, fok := convertListIndex(args[3], len(str), 0)
+	first, last, msg := rangeIndices(args[3], args[4], len(str))
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+	return tcl.SetResult(RetOk, str[first:last])
+}
+
+// Convert first and last indices to slice bounds of string of size length.
+func rangeIndices(firstIndex string, lastIndex string, length int) (int, int, string) {
+	first, _, fok := convertListIndex(firstIndex, length, 0)
 	if !fok {
-		return tcl.SetResult(RetError, "first index invalid")
+		return 0, 0, "first index invalid"
 	}
 
-	last, _, lok := convertListIndex(args[4], len(str), 0)
+	last, _, lok := convertListIndex(lastIndex, length, 0)
 	if !lok {
-		return tcl.SetResult(RetError, "last index invalid")
+		return 0, 0, "last index invalid"
 	}
 
 	first = max(0, first)
-	last = min(last, len(str))
+	last = min(last, length-1)
 	if last < 0 || first > last {
-		return tcl.SetResult(RetOk, "")
+		return 0, 0, ""
 	}
-	return tcl.SetResult(RetOk, str[first:last+1])
+	return first, last + 1, ""
 }
 
+// Largest string that string repeat will build.
+const maxStringLength = 1 << 30
+
 // Repeat a string number of times.
 func stringRepeat(tcl *Tcl, args []string) int {
-	if len(args) > 4 {
+	if len(args) != 4 {
 		return tcl.SetResult(RetError, "string repeat string count")
 	}
-	str := args[2]
-	count, _, ok := ConvertStringToNumber(args[3], 10, 0)
+	count, ok := formatInteger(args[3])
 	if !ok {
 		return tcl.SetResult(RetError, "count invalid "+args[3])
 	}
@@ -430,12 +607,10 @@ func stringRepeat(tcl *Tcl, args []string) int {
 	if count <= 0 {
 		return tcl.SetResult(RetOk, "")
 	}
-
-	result := ""
-	for range count {
-		result += str
+	if len(args[2]) > 0 && count > maxStringLength/len(args[2]) {
+		return tcl.SetResult(RetError, "result exceeds max size for a string")
 	}
-	return tcl.SetResult(RetOk, result)
+	return tcl.SetResult(RetOk, strings.Repeat(args[2], count))
 }
 
 // Replace range of characters in string with new string.
@@ -443,31 +618,19 @@ func stringReplace(tcl *Tcl, args []string) int {
 	if len(args) > 6 {
 		return tcl.SetResult(RetError, "string replace string first last ?newstring")
 	}
-	str := args[2]
+	str := []rune(args[2])
 	newstr := ""
 	if len(args) > 5 {
 		newstr = args[5]
 	}
-	first, _, fok := convertListIndex(args[3], len(str), 0)
-	if !fok {
-		return tcl.SetResult(RetError, "first index invalid")
+	first, last, msg := rangeIndices(args[3], args[4], len(str))
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
 	}
-
-	last, _, lok := convertListIndex(args[4], len(str), 0)
-	if !lok {
-		return tcl.SetResult(RetError, "last index invalid")
+	if first == last {
+		return tcl.SetResult(RetOk, args[2])
 	}
-	first = max(0, first)
-	last = min(last, len(str))
-	if last < 0 || first > last {
-		return tcl.SetResult(RetOk, str)
-	}
-
-	result := str[:first]
-	result += newstr
-	result += str[last+1:]
-
-	return tcl.SetResult(RetOk, result)
+	return tcl.SetResult(RetOk, string(str[:first])+newstr+string(str[last:]))
 }
 
 func stringToCase(tcl *Tcl, args []string) int {
@@ -546,23 +709,16 @@ func cmdInfo(tcl *Tcl, args []string) int {
 	}
 	list := []string{}
 	switch args[1] {
-	case "args": // info args procname
+	case "args", "body": // info args|body procname
 		if len(args) != 3 {
-			return tcl.SetResult(RetError, "info body procname")
+			return tcl.SetResult(RetError, "info "+args[1]+" procname")
 		}
-		cmd := tcl.cmds[args[2]]
-		if !cmd.proc {
-			return tcl.SetResult(RetError, args[2]+" not a proc")
+		cmd, ok := tcl.findCommand(args[2])
+		if !ok || !cmd.proc {
+			return tcl.SetResult(RetError, "\""+args[2]+"\" isn't a procedure")
 		}
-		return tcl.SetResult(RetOk, cmd.args)
-
-	case "body": // info body procname
-		if len(args) != 3 {
-			return tcl.SetResult(RetError, "info body procname")
-		}
-		cmd := tcl.cmds[args[2]]
-		if !cmd.proc {
-			return tcl.SetResult(RetError, args[2]+" not a proc")
+		if args[1] == "args" {
+			return tcl.SetResult(RetOk, cmd.args)
 		}
 		return tcl.SetResult(RetOk, cmd.body)
 
@@ -570,11 +726,11 @@ func cmdInfo(tcl *Tcl, args []string) int {
 		list = tcl.listCommands(false)
 
 	case "exists": // info exists varName
-		if len(args) < 4 {
-			err, _ := tcl.GetVarValue(args[2])
-			if err == RetOk {
-				return tcl.SetResult(RetOk, "1")
-			}
+		if len(args) != 3 {
+			return tcl.SetResult(RetError, "info exists varName")
+		}
+		if tcl.varExists(args[2]) {
+			return tcl.SetResult(RetOk, "1")
 		}
 		return tcl.SetResult(RetOk, "0")
 
@@ -616,7 +772,10 @@ func cmdInfo(tcl *Tcl, args []string) int {
 func (tcl *Tcl) listVars(local bool) []string {
 	res := []string{}
 
-	for v := range tcl.env.vars {
+	for v, variable := range tcl.env.vars {
+		if variable.undefined {
+			continue
+		}
 		if (local && tcl.env.local[v]) || !local {
 			res = append(res, v)
 		}
@@ -629,8 +788,10 @@ func (tcl *Tcl) listGlobals() []string {
 	res := []string{}
 
 	env := tcl.getLevel(true, 0)
-	for v := range env.vars {
-		res = append(res, v)
+	for v, variable := range env.vars {
+		if !variable.undefined {
+			res = append(res, v)
+		}
 	}
 	return res
 }
@@ -27,6 +27,8 @@ package tcl
 
 import (
 	"errors"
+	"io"
+	"os"
 	"strings"
 )
 
@@ -45,26 +47,54 @@ var (
 	ErrError = errors.New("error")
 )
 
-// Holds information about current running TCL session.
+// Handle on a running TCL session, interpreters returned by WithPrefix share
+// the session and differ only in the prefix given to registered commands.
 type Tcl struct {
-	env    *tclEnv            // Variables.
-	level  int                // Current nesting level.
-	cmds   map[string]*tclCmd // Supported commands.
-	result string             // Result from last command.
-	Data   map[string]any     // Place for extensions to store data.
+	*tclInterp        // Shared session.
+	prefix     string // Prefix added to registered commands.
+}
+
+// Holds information about current running TCL session.
+type tclInterp struct {
+	env       *tclEnv            // Variables.
+	level     int                // Current nesting level.
+	cmds      map[string]*tclCmd // Supported commands.
+	result    string             // Result from last command.
+	stdout    io.Writer          // Where puts output goes.
+	stderr    io.Writer          // Where error output goes.
+	events    *eventQueue        // Events waiting to run.
+	timers    *timerList         // Pending after commands.
+	waitVars  map[string]*tclVar // Table holding variable vwait is waiting on.
+	waitVar   string             // Variable vwait is waiting on.
+	waitDone  bool               // Variable being waited on was set.
+	global    *tclNamespace      // Global namespace.
+	bigInt    bool               // Expr uses arbitrary precision integers.
+	packages  *packageList       // Known and loaded packages.
+	coroutine *coroutine         // Running coroutine.
+	environ   *tclVar            // Global env array, follows process environment.
+	inError   bool               // Error is being returned, errorInfo is being built.
+	codeSet   bool               // ErrorCode was set for error being returned.
+	returning returnState        // Options of return being completed.
+	Data      map[string]any     // Place for extensions to store data.
 }
 
 // Commands, function amd default arguments.
 type tclCmd struct {
-	fn   func(*Tcl, []string) int
-	proc bool
-	args string // Procedure arguments.
-	body string // Procedure body.
+	fn      func(*Tcl, []string) int
+	proc    bool
+	args    string       // Procedure arguments.
+	body    string       // Procedure body.
+	traces  []traceEntry // Run when command is renamed or deleted.
+	deleted func(*Tcl)   // Called when command is deleted.
 }
 
 // Holds data relative to variables.
 type tclVar struct {
-	value string
+	value     string
+	array     map[string]*tclVar // Elements if variable is an array.
+	traces    []traceEntry       // Run when variable is read, written or unset.
+	tracing   bool               // A trace of variable is running.
+	undefined bool               // Variable only holds traces, it has not been set.
 }
 
 // Current running environment.
@@ -74,18 +104,67 @@ type tclEnv struct {
 	parent *tclEnv            // Parent nesting level.
 	args   string             // Current arguments.
 	level  int                // Level of this environment.
+	ns     *tclNamespace      // Current namespace.
 }
 
 // Create new environment to execute TCl commands.
 func NewTCL() *Tcl {
-	tcl := &Tcl{}
+	tcl := &Tcl{tclInterp: &tclInterp{}}
 	tcl.env = tcl.newEnv()
+	tcl.global = newNamespace(nil, "")
+	tcl.global.vars = tcl.env.vars
+	tcl.env.ns = tcl.global
+	tcl.environ = &tclVar{array: make(map[string]*tclVar)}
+	tcl.global.vars["env"] = tcl.environ
 	tcl.cmds = make(map[string]*tclCmd)
 	tcl.Data = make(map[string]any)
+	tcl.stdout = os.Stdout
+	tcl.stderr = os.Stderr
+	tcl.events = newEventQueue()
+	tcl.timers = newTimerList()
+	tcl.packages = newPackageList()
 	tcl.tclInitCommands()
 	return tcl
 }
 
+// Redirect standard output of interpreter.
+func (tcl *Tcl) SetOutput(w io.Writer) {
+	tcl.stdout = w
+}
+
+// Redirect error output of interpreter.
+func (tcl *Tcl) SetErrorOutput(w io.Writer) {
+	tcl.stderr = w
+}
+
+// Enable arbitrary precision integers in expr, slower but never overflows.
+func (tcl *Tcl) EnableBigIntegers(enable bool) {
+	tcl.bigInt = enable
+}
+
+// Return current standard output of interpreter.
+func (tcl *Tcl) Output() io.Writer {
+	return tcl.stdout
+}
+
+// Return current error output of interpreter.
+func (tcl *Tcl) ErrorOutput() io.Writer {
+	return tcl.stderr
+}
+
+// Return interpreter which registers commands with prefix added to name.
+// The returned interpreter shares the session of tcl, so commands, variables,
+// results and data are the same, and is intended to be passed to an extension
+// Init function.
+func WithPrefix(tcl *Tcl, prefix string) *Tcl {
+	return &Tcl{tclInterp: tcl.tclInterp, prefix: tcl.prefix + prefix}
+}
+
+// Initialize an extension with all of its commands under prefix.
+func (tcl *Tcl) AddCommandPrefix(prefix string, init func(*Tcl)) {
+	init(WithPrefix(tcl, prefix))
+}
+
 // Set results of last command.
 func (tcl *Tcl) SetResult(err int, str string) int {
 	tcl.result = str
@@ -99,7 +178,11 @@ func (tcl *Tcl) GetResult() string {
 
 // Evaluate a string, and return result code as string.
 func (tcl *Tcl) EvalString(str string) error {
+	tcl.inError = false
 	ret := tcl.eval(str, parserOptions{})
+	if ret == RetReturn {
+		ret = tcl.topReturn()
+	}
 	switch ret {
 	case RetOk, RetReturn:
 		return nil
@@ -143,6 +226,13 @@ func (tcl *Tcl) eval(str string, opts parserOptions) int {
 
 		switch p.token {
 		case tokVar: // If variable, replace with value.
+			if name, index, isArray := splitArrayName(val); isArray {
+				// Substitute array index.
+				if ret := tcl.eval(index, parserOptions{noEval: true, subst: true}); ret != RetOk {
+					return ret
+				}
+				val = name + "(" + tcl.result + ")"
+			}
 			ret, result := tcl.GetVarValue(val)
 			if ret != RetOk {
 				tcl.result = result
@@ -229,10 +319,52 @@ func (tcl *Tcl) doCommand(args []string) int {
 		return RetOk
 	}
 	tcl.result = ""
-	cmd, ok := tcl.cmds[args[0]]
+	cmd, ok := tcl.findCommand(args[0])
 	if !ok {
-		tcl.result = "unable to find command: " + args[0]
-		return RetError
+		cmd, ok = tcl.autoLoad(args[0])
+		if !ok {
+			tcl.addErrorInfo(args)
+			return RetError
+		}
+	}
+	ret := cmd.fn(tcl, args)
+	if ret == RetError {
+		tcl.addErrorInfo(args)
+	} else {
+		tcl.inError = false
+		tcl.codeSet = false
 	}
-	return cmd.fn(tcl, args)
+	return ret
+}
+
+// Longest command shown in errorInfo.
+const errorInfoMax = 150
+
+// Add command that failed to errorInfo. On first command of error errorInfo
+// is started with error message, and errorCode is set to NONE if not already
+// set by command.
+func (tcl *Tcl) addErrorInfo(args []string) {
+	cmd := escapeList(args)
+	if len(cmd) > errorInfoMax {
+		cmd = cmd[:errorInfoMax] + "..."
+	}
+	info := ""
+	if tcl.inError {
+		_, info = tcl.GetVarValue("::errorInfo")
+		info += "\n    invoked from within\n\"" + cmd + "\""
+	} else {
+		info = tcl.result + "\n    while executing\n\"" + cmd + "\""
+		if !tcl.codeSet {
+			tcl.setVar("::errorCode", "NONE")
+		}
+		tcl.inError = true
+		tcl.codeSet = false
+	}
+	tcl.setVar("::errorInfo", info)
+}
+
+// Set errorCode for error being returned.
+func (tcl *Tcl) SetErrorCode(code string) {
+	tcl.setVar("::errorCode", code)
+	tcl.codeSet = true
 }
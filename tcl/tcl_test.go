@@ -26,6 +26,11 @@
 package tcl
 
 import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -35,6 +40,24 @@ type cases struct {
 	res   int
 }
 
+// Evaluate each test case in a new interpreter, prepared by setup if given.
+func evalCases(t *testing.T, testCases []cases, setup func(*Tcl)) {
+	t.Helper()
+	for _, test := range testCases {
+		tcl := NewTCL()
+		if setup != nil {
+			setup(tcl)
+		}
+		ret := tcl.eval(test.test, parserOptions{})
+		if test.res != ret {
+			t.Errorf("Eval did not return correct results for %s, got: %d, expected %d", test.test, ret, test.res)
+		}
+		if test.match != tcl.GetResult() {
+			t.Errorf("Eval %s returned wrong result, got: '%s' expected: '%s'", test.test, tcl.GetResult(), test.match)
+		}
+	}
+}
+
 func TestUnescape(t *testing.T) {
 	testCases := []cases{
 		{"", "", 0},
@@ -54,6 +77,7 @@ func TestUnescape(t *testing.T) {
 		{"\\x310", "10", 2},
 		{"\\x31\\x312", "112", 3},
 		{"x\\x31\\x312", "x112", 4},
+		{"\\0", "\x00", 1},
 	}
 
 	for _, test := range testCases {
@@ -105,6 +129,18 @@ func TestGetVars(t *testing.T) {
 	}
 }
 
+func TestParseArgsNull(t *testing.T) {
+	tcl := NewTCL()
+	r := tcl.eval("list a \\0 b", parserOptions{})
+	if r != RetOk {
+		t.Fatal("Eval did not return OK")
+	}
+	list := tcl.ParseArgs(tcl.GetResult())
+	if len(list) != 3 || list[0] != "a" || list[1] != "\x00" || list[2] != "b" {
+		t.Errorf("ParseArgs did not return null element got: %q", list)
+	}
+}
+
 func TestEval(t *testing.T) {
 	testCases := []cases{
 		{"expr 1 + 2", "3", RetOk},
@@ -112,6 +148,22 @@ func TestEval(t *testing.T) {
 		{"expr -2", "-2", RetOk},
 		{"expr - 2", "-2", RetOk},
 		{"expr = 2", "invalid operator", RetError},
+		{"expr 3.14 * 2", "6.28", RetOk},
+		{"expr 1/2", "0", RetOk},
+		{"expr 1/2.0", "0.5", RetOk},
+		{"expr 10 - 2.5", "7.5", RetOk},
+		{"expr 1e3 + 1", "1001.0", RetOk},
+		{"expr 2.0 * 3", "6.0", RetOk},
+		{"expr 2.5", "2.5", RetOk},
+		{"expr - 2.5", "-2.5", RetOk},
+		{"expr abs -2.5", "2.5", RetOk},
+		{"expr 1.5 > 1", "1", RetOk},
+		{"expr 1.5 == 1.5", "1", RetOk},
+		{"expr 0x1e + 1", "31", RetOk},
+		{"expr 1 / 0", "divide by zero", RetError},
+		{"expr 1.0 / 0", "divide by zero", RetError},
+		{"expr 1.5 and 1", "can't use floating-point value as operand of and", RetError},
+		{"set a 1.5; expr $a * $a", "2.25", RetOk},
 		{"set x \"$\"", "$", RetOk},
 		{"set x \"val$\"", "val$", RetOk},
 		{"set x \"${}\"", "${}", RetOk},
@@ -119,6 +171,96 @@ func TestEval(t *testing.T) {
 		{"set x \"${\"", "${", RetOk},
 		{"proc foo {a} {set v $a}; foo b", "b", RetOk},
 		{"proc foo {} {set v a}; foo", "a", RetOk},
+		{"proc foo {a {b 2} {c {x y}}} {list $a $b $c}; foo 1", "1 2 {x y}", RetOk},
+		{"proc foo {a {b 2}} {list $a $b}; foo 1 3", "1 3", RetOk},
+		{"proc foo {a {b 2}} {list $a $b}; foo", "wrong # args: should be \"foo a ?b?\"", RetError},
+		{"proc foo {a} {set a}; foo 1 2", "wrong # args: should be \"foo a\"", RetError},
+		{"proc foo {a args} {list $a $args}; foo 1 2 {3 4}", "1 {2 {3 4}}", RetOk},
+		{"proc foo {a args} {llength $args}; foo 1", "0", RetOk},
+		{"proc foo {{a 1} args} {list $a $args}; foo", "1 {}", RetOk},
+		{"proc foo {a args} {set a}; foo", "wrong # args: should be \"foo a ?arg ...?\"", RetError},
+		{"proc foo {args x} {set x}; foo 1", "wrong # args: should be \"foo args x\"", RetError},
+		{"apply {{x y} {expr $x + $y}} 1 2", "3", RetOk},
+		{"apply {x {set x}} hi", "hi", RetOk},
+		{"set x 5; apply {{} {info exists x}}", "0", RetOk},
+		{"lmap v {1 2} {apply {x {expr $x * 3}} $v}", "3 6", RetOk},
+		{"namespace eval lam {proc who {} {return lam}}; apply {{} {who} lam}", "lam", RetOk},
+		{"apply {{x} {return $x; set x 2}} 1", "1", RetOk},
+		{"apply x", "can't interpret \"x\" as a lambda expression", RetError},
+		{"apply", "apply lambdaExpr ?arg ...", RetError},
+		{"try {set x 1}", "1", RetOk},
+		{"try {error oops} on error msg {set msg}", "oops", RetOk},
+		{"try {error oops} on error {msg opts} {dict get $opts -errorcode}", "NONE", RetOk},
+		{"try {error oops} on error {msg opts} {dict get $opts -code}", "1", RetOk},
+		{"try {throw {APP IO} failed} trap {APP IO} {msg opts} {list $msg [dict get $opts -errorcode]}", "failed {APP IO}", RetOk},
+		{"try {set x 1} on ok {msg opts} {set opts}", "-code 0 -level 0", RetOk},
+		{"try {throw {APP IO} failed} trap {APP NET} msg {set r net} trap APP msg {set r app}", "app", RetOk},
+		{"try {throw {APP IO} failed} trap {APP NET} msg {set r net}", "failed", RetError},
+		{"try {set x 2} on ok r {expr $r + 1}", "3", RetOk},
+		{"foreach i {1 2} {try {break} on break {} {set r brk}}; set r", "brk", RetOk},
+		{"try {error a} on return {} - on error {} {set r shared}", "shared", RetOk},
+		{"set r {}; try {set x 1} finally {set r done}; set r", "done", RetOk},
+		{"set r {}; catch {try {error a} finally {set r done}}; set r", "done", RetOk},
+		{"set r {}; catch {try {error a} on error {} {error b} finally {set r done}} m; list $r $m", "done b", RetOk},
+		{"try {set x 1} finally {error fin}", "fin", RetError},
+		{"try {error a} on bogus {} {}", "bad completion code \"bogus\": must be ok, error, return, break, continue, or an integer", RetError},
+		{"try {error a} else {} {}", "bad handler \"else\": must be \"on\", \"trap\", or \"finally\"", RetError},
+		{"proc f {} {try {return 5} finally {}; return 6}; f", "5", RetOk},
+		{"catch {throw {POSIX ENOENT} {no file}} m; list $m $errorCode", "{no file} {POSIX ENOENT}", RetOk},
+		{"throw {} msg", "type must be non-empty list", RetError},
+		{"catch {error oops}; list $errorInfo $errorCode", "{oops\n    while executing\n\"error oops\"} NONE", RetOk},
+		{"proc f {} {error oops}; catch {f}; set errorInfo", "oops\n    while executing\n\"error oops\"\n    invoked from within\n\"f\"", RetOk},
+		{"catch {error oops {my info} {MY CODE}}; list $errorInfo $errorCode", "{my info\n    invoked from within\n\"error oops {my info} {MY CODE}\"} {MY CODE}", RetOk},
+		{"catch {throw {A B} x}; catch {error y}; set errorCode", "NONE", RetOk},
+		{"catch {error first}; set x 1; catch {error second}; set errorInfo", "second\n    while executing\n\"error second\"", RetOk},
+		{"catch {set nothere}; set errorInfo", "value: nothere not found\n    while executing\n\"set nothere\"", RetOk},
+		{"catch {nothere}; list $errorInfo $errorCode", "{unable to find command: nothere\n    while executing\n\"nothere\"} NONE", RetOk},
+		{"proc f {} {nothere}; catch f; set errorInfo", "unable to find command: nothere\n    while executing\n\"nothere\"\n    invoked from within\n\"f\"", RetOk},
+		{"error a b c d", "error message ?info ?code", RetError},
+		{"catch {set x 1}", "0", RetOk},
+		{"catch {error a}", "1", RetOk},
+		{"proc f {} {catch {return 5} r; list $r}; f", "5", RetOk},
+		{"proc f {} {catch {return 5}}; f", "2", RetOk},
+		{"foreach i {1} {set c [catch break]}; set c", "3", RetOk},
+		{"foreach i {1} {set c [catch continue]}; set c", "4", RetOk},
+		{"catch {set x 1} r o; list $r $o", "1 {-code 0 -level 0}", RetOk},
+		{"catch {throw {MY ERR} bad} r o; list [dict get $o -code] [dict get $o -errorcode] [dict get $o -errorinfo]",
+			"1 {MY ERR} {bad\n    while executing\n\"throw {MY ERR} bad\"}", RetOk},
+		{"proc f {} {catch {return 5} r o; set o}; f", "-code 0 -level 1", RetOk},
+		{"catch {exit 3}", "3", RetExit},
+		{"catch a b c d", "catch script ?resultVar ?optionsVar", RetError},
+		{"proc f {} {return -code error oops}; f", "oops", RetError},
+		{"proc f {} {return -code error -errorcode {MY ERR} oops}; catch f m; list $m $errorCode", "oops {MY ERR}", RetOk},
+		{"proc f {} {return -code error -errorinfo {from f} oops}; catch f; set errorInfo", "from f\n    invoked from within\n\"f\"", RetOk},
+		{"proc f {} {return -code break}; set n 0; foreach i {1 2 3} {incr n; f}; set n", "1", RetOk},
+		{"proc f {} {return -code continue}; set n 0; foreach i {1 2 3} {f; incr n}; set n", "0", RetOk},
+		{"proc f {} {return -code 1 bad}; catch f", "1", RetOk},
+		{"proc f {} {return -code ok -level 1 x}; f", "x", RetOk},
+		{"proc inner {} {return -level 2 up}; proc outer {} {inner; return no}; outer", "up", RetOk},
+		{"proc inner {} {return -level 2 -code error up}; proc outer {} {inner; return no}; catch outer m; set m", "up", RetOk},
+		{"proc f {} {set x [return -level 0 val]; return $x.1}; f", "val.1", RetOk},
+		{"catch {return -level 0 -code error bad} m", "1", RetOk},
+		{"proc f {} {catch {return -code error -level 2 x} r o; set o}; f", "-code 1 -level 2", RetOk},
+		{"proc f {} {return -code bogus}; f", "bad completion code \"bogus\": must be ok, error, return, break, continue, or an integer", RetError},
+		{"proc f {} {return -level -1 x}; f", "bad -level value: expected non-negative integer but got \"-1\"", RetError},
+		{"proc f {} {return a b}; f", "wrong number of arguments to return", RetError},
+		{"proc f {} {return -x}; f", "-x", RetOk},
+		{"proc gen {} {yield a; yield b; return c}; coroutine g gen", "a", RetOk},
+		{"proc gen {} {yield a; yield b; return c}; coroutine g gen; list [g] [g]", "b c", RetOk},
+		{"proc gen {} {yield a; return c}; coroutine g gen; g; g", "unable to find command: g", RetError},
+		{"proc cnt {} {set i 0; while 1 {incr i; yield $i}}; coroutine c cnt; c; c; c", "4", RetOk},
+		{"proc acc {} {set t 0; while 1 {set t [expr $t + [yield $t]]}}; coroutine s acc; s 2; s 5", "7", RetOk},
+		{"proc gen {x} {set y [yield $x]; error $y}; coroutine g gen 1; g oops", "oops", RetError},
+		{"proc gen {} {yield 1}; coroutine g gen; coroutine g gen", "command \"g\" already exists", RetError},
+		{"proc gen {} {g}; coroutine g gen", "coroutine \"g\" is already running", RetError},
+		{"proc gen {} {yield 1}; proc f {} {set v 2; coroutine g gen; set v}; f", "2", RetOk},
+		{"yield 1", "yield can only be called in a coroutine", RetError},
+		{"proc gen {} {global st; try {yield 1} finally {set st unwound}}; set st {}; coroutine g gen; rename g {}; set st", "unwound", RetOk},
+		{"proc gen {} {global st; catch {yield 1}; set st caught}; set st {}; coroutine g gen; rename g {}; set st", "", RetOk},
+		{"proc gen {} {global st; try {yield 1} finally {set st unwound}}; set st {}; coroutine g gen; proc g {} {}; set st", "unwound", RetOk},
+		{"proc gen {} {global st; try {yield 1} finally {set st unwound}}; set st {}; namespace eval ns {coroutine g gen}; namespace delete ns; set st", "unwound", RetOk},
+		{"proc gen {} {global st; try {rename g {}; yield 1} finally {set st unwound}}; set st {}; list [coroutine g gen] $st [catch g]", "1 unwound 1", RetOk},
+		{"proc gen {} {global st; try {yield 1} finally {set st unwound; yield 2}}; set st {}; coroutine g gen; rename g {}; set st", "unwound", RetOk},
 		{"set var 0 ;for {set i 1} {$i<=10} {incr i} { append var \",\" $i}; set var", "0,1,2,3,4,5,6,7,8,9,10", RetOk},
 		{"break", "", RetBreak},
 		{"set y {}; for {set x 0} {$x<10} {incr x} { if {$x > 5} { break } ;append y \",$x\" }; set y", ",0,1,2,3,4,5", RetOk},
@@ -135,9 +277,17 @@ func TestEval(t *testing.T) {
 		{"set var 1; lappend var 2; lappend var 3 4 5", "1 2 3 4 5", RetOk},
 		{"set var {}; lappend x 1 2 3; set x", "1 2 3", RetOk},
 		{"lindex {a b c}", "a b c", RetOk},
+		{"lappend x 1 2 3", "1 2 3", RetOk},
+		{"lappend x 1 2 3; set x", "1 2 3", RetOk},
+		{"lappend x", "", RetOk},
+		{"lappend x; info exists x", "1", RetOk},
+		{"set x {a b}; lappend x", "a b", RetOk},
+		{"set x {a b}; lappend x c; lappend x {d e}", "a b c {d e}", RetOk},
+		{"lappend a(x) 1; lappend a(x) 2; set a(x)", "1 2", RetOk},
 		{"lindex {a b c} {}", "a b c", RetOk},
 		{"lindex {a b c} 0", "a", RetOk},
 		{"lindex {a b c} 2", "c", RetOk},
+		{"lindex {{} b} 1", "b", RetOk},
 		{"lindex {a b c} end", "c", RetOk},
 		{"lindex {a b c} end-1", "b", RetOk},
 		{"lindex {{a b c} {d e f} {g h i}} 2 1", "h", RetOk},
@@ -197,6 +347,32 @@ func TestEval(t *testing.T) {
 		{"lsort {{a 5} { c 3} {b 4} {e 1} {d 2}}", "{ c 3} {a 5} {b 4} {d 2} {e 1}", RetOk},
 		{"lsort -integer {5 3 1 2 11 4}", "1 2 3 4 5 11", RetOk},
 		{"lsort -integer {1 2 0x5 7 0 4 -1}", "-1 0 1 2 4 0x5 7", RetOk},
+		{"lsort -unique {c a b a c}", "a b c", RetOk},
+		{"lsort -unique -decreasing {c a b a c}", "c b a", RetOk},
+		{"lsort -unique -integer {3 03 1 2 1}", "1 2 3", RetOk},
+		{"lsort -unique -nocase {b A a B}", "A b", RetOk},
+		{"lsort -unique -real {1.0 1 0.5 2.50 2.5}", "0.5 1.0 2.50", RetOk},
+		{"lsort -real {10 2.5 -1 3e1}", "-1 2.5 10 3e1", RetOk},
+		{"lsort -nocase {b C a}", "a b C", RetOk},
+		{"proc cmp {a b} {expr [string length $a] - [string length $b]}; lsort -unique -command cmp {aa b cc d}", "b aa", RetOk},
+		{"lsort -unique", "lsort ?options list", RetError},
+		{"lsort -index 1 {{a 3} {b 1} {c 2}}", "{b 1} {c 2} {a 3}", RetOk},
+		{"lsort -index end {{a x 3} {b 1} {c y 2}}", "{b 1} {c y 2} {a x 3}", RetOk},
+		{"lsort -index end-1 {{a x 3} {b 1} {c y 2}}", "{b 1} {a x 3} {c y 2}", RetOk},
+		{"lsort -index 1 -integer -decreasing {{a 3} {b 10} {c 2}}", "{b 10} {a 3} {c 2}", RetOk},
+		{"lsort -index 1 -real {{a 2.5} {b 1e1} {c -1}}", "{c -1} {a 2.5} {b 1e1}", RetOk},
+		{"lsort -index 0 -nocase {{B 1} {a 2} {c 3}}", "{a 2} {B 1} {c 3}", RetOk},
+		{"lsort -index 0 -unique {{a 1} {b 2} {a 3}}", "{a 1} {b 2}", RetOk},
+		{"lsort -index 2 {{a 1} {b 2}}", "element 2 missing from sublist \"a 1\"", RetError},
+		{"lsort -index x {{a 1} {b 2}}", "bad index \"x\"", RetError},
+		{"lsort -stride 2 {c 3 a 1 b 2}", "a 1 b 2 c 3", RetOk},
+		{"lsort -stride 2 -index 1 -integer {c 3 a 10 b 2}", "b 2 c 3 a 10", RetOk},
+		{"lsort -stride 2 -index end -decreasing {x b y a z c}", "z c x b y a", RetOk},
+		{"lsort -stride 3 {b {1 2} q a {3 4} r}", "a {3 4} r b {1 2} q", RetOk},
+		{"lsort -stride 2 -unique {a 1 b 2 a 3}", "a 1 b 2", RetOk},
+		{"lsort -stride 2 {a 1 b}", "list size must be a multiple of the stride length", RetError},
+		{"lsort -stride 0 {a 1}", "stride length must be at least 1", RetError},
+		{"lsort -stride 2 -index 2 {a 1 b 2}", "index \"2\" out of range of stride", RetError},
 		{"split \"comp.lang.tcl.announce\" .", "comp lang tcl announce", RetOk},
 		{"split \"alpha beta gamma\" \"temp\"", "al {ha b} {} {a ga} {} a", RetOk},
 		{"split \"Example with {unbalanced brace character\"", "Example with \\{unbalanced brace character", RetOk},
@@ -244,16 +420,541 @@ func TestEval(t *testing.T) {
 		{"string range \"abcde\" 0 3", "abcd", RetOk},
 		{"string range \"abcdefgh\" 3 5", "def", RetOk},
 		{"string range \"abcdefgh\" 5 3", "", RetOk},
+		{"string range \"abcdefgh\" 5 end", "fgh", RetOk},
+		{"string range \"abc\" 1 10", "bc", RetOk},
+		{"string range \"中文abc\" 1 2", "文a", RetOk},
+		{"string range abc", "string range string first last", RetError},
+		{"string byterange \"abcdefgh\" 0 3", "abcd", RetOk},
+		{"string byterange \"中文abc\" 0 2", "中", RetOk},
+		{"string byterange \"中文abc\" 6 end", "abc", RetOk},
+		{"string length [string byterange \"中文abc\" 1 2]", "2", RetOk},
+		{"string byterange \"abc\" 0 0", "a", RetOk},
+		{"string byterange \"abc\" 2 2", "c", RetOk},
+		{"string byterange \"abc\" 3 5", "", RetOk},
+		{"string byterange \"abc\" x 1", "first index invalid", RetError},
 		{"string index \"abcde\" 3", "d", RetOk},
 		{"string index \"abcde\" end-2", "c", RetOk},
 		{"string index \"abcde\" 10", "", RetOk},
+		{"string index \"héllo\" 1", "é", RetOk},
+		{"string index \"中文abc\" end", "c", RetOk},
+		{"string length \"héllo\"", "5", RetOk},
+		{"string bytelength \"héllo\"", "6", RetOk},
 		{"string match \"fred*\" \"freda\"", "1", RetOk},
 		{"string equal \"fred*\" \"freda\"", "0", RetOk},
 		{"string equal -nocase -length 3 \"abcde\" \"abcdefg\"", "1", RetOk},
 		{"string equal -length 0 a b", "1", RetOk},
+		{"string compare file10 file9", "-1", RetOk},
+		{"string compare -dictionary file10 file9", "1", RetOk},
+		{"string compare -dictionary file9 file10", "-1", RetOk},
+		{"string compare -dictionary file010 file10", "0", RetOk},
+		{"string compare -dictionary a2b3 a2b12", "-1", RetOk},
+		{"string compare -dictionary abc abcd", "-1", RetOk},
+		{"string compare -dictionary -nocase FILE10 file9", "1", RetOk},
+		{"string equal -dictionary x01 x1", "1", RetOk},
 		{"string replace \"this is a bad example\" 10 12 good", "this is a good example", RetOk},
+		{"string replace \"héllo\" 1 1 e", "hello", RetOk},
+		{"string replace abc 1 end", "a", RetOk},
+		{"string replace abc 2 10 x", "abx", RetOk},
+		{"string replace abc 2 1 x", "abc", RetOk},
 		{"string hello", "string unknown function", RetError},
 		{"string repeat \"abc\" 3", "abcabcabc", RetOk},
+		{"string repeat x 0", "", RetOk},
+		{"string repeat x -1", "", RetOk},
+		{"string repeat abc notanumber", "count invalid notanumber", RetError},
+		{"string repeat abc 3x", "count invalid 3x", RetError},
+		{"string repeat abc", "string repeat string count", RetError},
+		{"string repeat abc 1000000000000", "result exceeds max size for a string", RetError},
+		{"string repeat {} 1000000000000", "", RetOk},
+		{"string format \"%05d\" 7", "00007", RetOk},
+		{"string format \"%s and %s\" hello world", "hello and world", RetOk},
+		{"format \"%-5s|%5s|\" ab cd", "ab   |   cd|", RetOk},
+		{"format \"%x %X %o %c %i%%\" 255 255 8 65 12", "ff FF 10 A 12%", RetOk},
+		{"format %b 10", "1010", RetOk},
+		{"format %b 0", "0", RetOk},
+		{"format %b 255", "11111111", RetOk},
+		{"format %08b 5", "00000101", RetOk},
+		{"format %+b 3", "+11", RetOk},
+		{"format %d", "not enough arguments for all format specifiers", RetError},
+		{"format %.2f 3.14159", "3.14", RetOk},
+		{"format %8.3f 2", "   2.000", RetOk},
+		{"format %-8.1f| 2.25", "2.2     |", RetOk},
+		{"format %+.1f 2.5", "+2.5", RetOk},
+		{"format %e 1234.5", "1.234500e+03", RetOk},
+		{"format %.3E 0.00012", "1.200E-04", RetOk},
+		{"format %g 0.5", "0.5", RetOk},
+		{"format %G 1e20", "1E+20", RetOk},
+		{"format %*d 5 42", "   42", RetOk},
+		{"format %-*d| 4 7", "7   |", RetOk},
+		{"format %.*f 1 2.25", "2.2", RetOk},
+		{"format %*d 5", "not enough arguments for all format specifiers", RetError},
+		{"format %f abc", "expected floating-point number but got \"abc\"", RetError},
+		{"format %d hello", "expected integer but got \"hello\"", RetError},
+		{"namespace eval ::a::b::c {}; namespace children ::a", "::a::b", RetOk},
+		{"namespace eval ::a::b::c {}; namespace children :: *", "::a", RetOk},
+		{"namespace eval ::a::b::c {}; namespace eval ::a::d {}; namespace children ::a", "::a::b ::a::d", RetOk},
+		{"namespace eval ::a::b::c {}; namespace eval ::a::d {}; namespace children ::a b*", "::a::b", RetOk},
+		{"namespace eval ::a::b::c {}; namespace eval ::a::d {}; namespace children ::a -recursive", "::a::b ::a::b::c ::a::d", RetOk},
+		{"namespace eval a {namespace eval b {}}; namespace eval a {namespace children}", "::a::b", RetOk},
+		{"namespace eval a {set x 1}; set x 2; namespace eval a {set x}", "1", RetOk},
+		{"namespace children ::nothere", "namespace ::nothere not found", RetError},
+		{
+			"namespace eval myns {proc a {} {variable x; set x 10}; proc b {} {variable x; incr x; set x}}; myns::a; myns::b",
+			"11", RetOk,
+		},
+		{"namespace eval myns {variable x 5; proc a {} {variable x; set x}}; ::myns::a", "5", RetOk},
+		{"namespace eval myns {proc a {} {variable y 5; set y}}; myns::a; namespace eval myns {set y}", "5", RetOk},
+		{"namespace eval myns {proc a {} {variable y 5}}; set y 1; myns::a; set y", "1", RetOk},
+		{"namespace eval myns {proc a {} {return 1}; proc b {} {a}}; myns::b", "1", RetOk},
+		{"namespace eval myns {proc a {} {return 1}}; a", "unable to find command: a", RetError},
+		{"proc a {} {variable z 3}; a; set z", "3", RetOk},
+		{"namespace eval a {proc f {} {return 1}; variable x 1}; namespace delete a; catch a::f", "1", RetOk},
+		{"namespace eval a {proc f {} {return 1}; variable x 1}; namespace delete ::a; info exists ::a::x", "0", RetOk},
+		{"namespace eval a::b {proc f {} {return 1}}; namespace eval c {}; namespace delete a; namespace children ::", "::c", RetOk},
+		{"namespace eval a::b {proc f {} {return 1}}; namespace delete a; info commands a::*", "", RetOk},
+		{"namespace eval a::b {}; namespace delete a::b; namespace children a", "", RetOk},
+		{"namespace eval ::util {proc f {} {return 1}}; namespace path ::util; f", "1", RetOk},
+		{"namespace eval ::util {proc f {} {return 1}}; namespace path ::util; f; namespace eval ::util {proc f {} {return 2}}; f", "2", RetOk},
+		{"namespace eval ::util {}; namespace eval ::math {}; namespace path {::util ::math}; namespace path", "::util ::math", RetOk},
+		{"namespace path", "", RetOk},
+		{"namespace eval ::a {proc f {} {return a}}; namespace eval ::b {proc f {} {return b}}; namespace eval c {namespace path {::b ::a}; f}", "b", RetOk},
+		{"namespace eval ::a {proc f {} {return a}}; namespace eval c {proc f {} {return c}; namespace path ::a; f}", "c", RetOk},
+		{"namespace eval ::util {proc f {} {return 1}}; f", "unable to find command: f", RetError},
+		{"namespace path ::nothere", "namespace ::nothere not found", RetError},
+		{"proc ::util::add {a b} {expr $a + $b}; ::util::add 1 2", "3", RetOk},
+		{"proc ::util::add {a b} {expr $a + $b}; util::add 1 2", "3", RetOk},
+		{"proc ::util::add {a b} {expr $a + $b}; namespace children ::", "::util", RetOk},
+		{"proc ::util::f {} {variable x 4}; ::util::f; set ::util::x", "4", RetOk},
+		{"proc ::g {} {return 1}; g", "1", RetOk},
+		{"namespace eval a {proc ::g {} {return 1}}; g", "1", RetOk},
+		{"namespace eval a {proc b::f {} {return 2}}; a::b::f", "2", RetOk},
+		{"proc util::f {} {return 3}; namespace eval other {util::f}", "3", RetOk},
+		{"namespace eval ::a {}; namespace exists ::a", "1", RetOk},
+		{"namespace exists ::nonexistent", "0", RetOk},
+		{"namespace exists ::nonexistent; namespace children ::", "", RetOk},
+		{"namespace exists ::", "1", RetOk},
+		{"namespace eval ::a::b {}; namespace eval a {namespace exists b}", "1", RetOk},
+		{"namespace exists", "namespace exists name", RetError},
+		{"namespace delete ::", "can't delete global namespace", RetError},
+		{"namespace delete nothere", "namespace nothere not found", RetError},
+		{"namespace current", "::", RetOk},
+		{"namespace eval a::b {namespace current}", "::a::b", RetOk},
+		{"namespace eval a::b {namespace parent}", "::a", RetOk},
+		{"namespace eval a {}; namespace parent a", "::", RetOk},
+		{"namespace parent", "", RetOk},
+		{"namespace parent nothere", "namespace nothere not found", RetError},
+		{"namespace eval lib {namespace export get* put; namespace export}", "get* put", RetOk},
+		{"namespace eval lib {namespace export a; namespace export -clear b; namespace export}", "b", RetOk},
+		{"namespace export ::x::y", "invalid export pattern \"::x::y\": pattern can't specify a namespace", RetError},
+		{"namespace eval lib {namespace export get*; proc getx {} {return x}; proc hidden {} {}}; namespace import lib::*; getx", "x", RetOk},
+		{"namespace eval lib {namespace export get*; proc getx {} {return x}; proc hidden {} {}}; namespace import lib::*; catch hidden", "1", RetOk},
+		{"namespace eval lib {namespace export *; proc f {} {namespace current}}; namespace eval app {namespace import ::lib::f; f}", "::lib", RetOk},
+		{"namespace eval lib {namespace export *; proc f {} {}}; proc f {} {}; namespace import lib::f", "can't import command \"f\": already exists", RetError},
+		{"namespace eval lib {namespace export *; proc f {} {return 1}}; proc f {} {}; namespace import -force lib::f; f", "1", RetOk},
+		{"namespace import f", "import pattern \"f\" must be qualified", RetError},
+		{"namespace which set", "::set", RetOk},
+		{"namespace eval lib {proc f {} {}; namespace which f}", "::lib::f", RetOk},
+		{"namespace eval lib {namespace which -command set}", "::set", RetOk},
+		{"namespace which nothere", "", RetOk},
+		{"set g 1; namespace eval lib {variable v 1; list [namespace which -variable v] [namespace which -variable g]}", "::lib::v ::g", RetOk},
+		{"namespace eval lib {variable v 1}; namespace which -variable lib::v", "::lib::v", RetOk},
+		{"namespace which -variable nothere", "", RetOk},
+		{"set a(x) 1; info exists a(x)", "1", RetOk},
+		{"set a(x) 1; info exists a(y)", "0", RetOk},
+		{"set a(x) 1; info exists a", "0", RetOk},
+		{"set x 1; proc f {} {info exists ::x}; f", "1", RetOk},
+		{"proc f {} {info exists ::x}; f", "0", RetOk},
+		{"namespace eval myns {variable x 2}; info exists ::myns::x", "1", RetOk},
+		{"namespace eval myns {}; info exists ::myns::x", "0", RetOk},
+		{"info exists ::nons::x", "0", RetOk},
+		{"set a(x) 1; set a(y) 2; set i y; set a($i)", "2", RetOk},
+		{"set a(x) 1; set b \"$a(x)\"; set b", "1", RetOk},
+		{"set a(x) 1; set a([string index xyz 0])", "1", RetOk},
+		{"set a(x) 1; unset a(x); info exists a(x)", "0", RetOk},
+		{"set a(x) 1; set a", "can't read \"a\": variable is array", RetError},
+		{"set a 1; set a(x) 2", "can't set \"a(x)\": variable isn't array", RetError},
+		{"set ::myns::x 1", "can't set \"::myns::x\": parent namespace doesn't exist", RetError},
+		{"namespace eval myns {}; set ::myns::x 1; namespace eval myns {set x}", "1", RetOk},
+		{"lindex {a b c} {}", "a b c", RetOk},
+		{"lindex {} 0", "", RetOk},
+		{"lindex {a b c} 5", "", RetOk},
+		{"lindex {a {} c} 1", "", RetOk},
+		{"lrange {} 0 end", "", RetOk},
+		{"lrange {a b c} 1 end", "b c", RetOk},
+		{"lrange {a b c} 2 5", "c", RetOk},
+		{"lrange {a b c} 2 1", "", RetOk},
+		{"list", "", RetOk},
+		{"dict create", "", RetOk},
+		{"dict create a 1", "a 1", RetOk},
+		{"dict create a 1 b 2", "a 1 b 2", RetOk},
+		{"dict create a 1 b {2 3} a 4", "a 4 b {2 3}", RetOk},
+		{"dict create a", "dict create ?key value ...", RetError},
+		{"dict exists {a {b {c 1}}} a b c", "1", RetOk},
+		{"dict exists {a {b {c 1}}} a b d", "0", RetOk},
+		{"dict exists {a {b {c 1}}} a", "1", RetOk},
+		{"dict exists {a {b {c 1}}} a b c d", "0", RetOk},
+		{"dict exists {a {b x y}} a b c", "0", RetOk},
+		{"dict exists {} key", "0", RetOk},
+		{"dict exists {a} a", "missing value to go with key", RetError},
+		{"dict exists {a 1}", "dict exists dictionary key ?key ...", RetError},
+		{"dict lappend d key a", "key a", RetOk},
+		{"set d {key {a b}}; dict lappend d key c", "key {a b c}", RetOk},
+		{"set d {x 1 key a}; dict lappend d key b {c d}; set d", "x 1 key {a b {c d}}", RetOk},
+		{"set d {x 1}; dict lappend d key", "x 1 key {}", RetOk},
+		{"set d {x}; dict lappend d key a", "missing value to go with key", RetError},
+		{"dict lappend d", "dict lappend dictVarName key ?value ...", RetError},
+		{"dict remove {a 1 b 2 c 3} b", "a 1 c 3", RetOk},
+		{"set d {a 1 b 2}; dict remove $d nonexistentKey", "a 1 b 2", RetOk},
+		{"dict remove {a 1 b 2 c 3} a c", "b 2", RetOk},
+		{"dict replace {} a 1", "a 1", RetOk},
+		{"set d {a 1 b 2}; dict replace $d a 3 c {4 5}", "a 3 b 2 c {4 5}", RetOk},
+		{"set d {a 1 b 2}; dict replace $d a 3; set d", "a 1 b 2", RetOk},
+		{"dict replace {a 1 b} a 2", "missing value to go with key", RetError},
+		{"dict replace {a 1} a", "dict replace dictionary ?key value ...", RetError},
+		{"dict get {a 1 b 2} b", "2", RetOk},
+		{"dict get {a 1 b 2}", "a 1 b 2", RetOk},
+		{"dict get {a {x 5}} a x", "5", RetOk},
+		{"dict get {a 1} b", "key \"b\" not known in dictionary", RetError},
+		{"dict get -default 0 {a 1} b", "0", RetOk},
+		{"dict get -default 0 {a 1} a", "1", RetOk},
+		{"dict get -default none {a {x 5}} a y", "none", RetOk},
+		{"dict get -default \"\" {a 1} b", "", RetOk},
+		{"dict get -default", "dict get ?-default value dictionary ?key ...", RetError},
+		{"dict getdef {a 1} b 0", "0", RetOk},
+		{"dict getdef {a {x 5}} a x 0", "5", RetOk},
+		{"dict getdef {a 1} b", "dict getdef dictionary ?key ... key default", RetError},
+		{"set d {a 1}; dict append d a 2 3", "a 123", RetOk},
+		{"dict append d b x; set d", "b x", RetOk},
+		{"dict filter {a 1 b 2 ab 3} key a*", "a 1 ab 3", RetOk},
+		{"dict filter {a 1 b 2 c 3} value 2 3", "b 2 c 3", RetOk},
+		{"dict filter {a 1 b 2 c 3} script {k v} {expr $v > 1}", "b 2 c 3", RetOk},
+		{"dict filter {a 1 b 2} script {k v} {set k foo}", "expected boolean value but got \"foo\"", RetError},
+		{"dict filter {a 1} bogus", "dict filter dictionary key|value ?pattern ...|script {keyVarName valueVarName} script", RetError},
+		{"set r {}; dict for {k v} {a 1 b 2} {append r $k=$v,}; set r", "a=1,b=2,", RetOk},
+		{"set r {}; dict for {k v} {a 1 b 2 c 3} {if {$k == \"b\"} break; append r $k}; set r", "a", RetOk},
+		{"set r {}; dict for {k v} {a 1 b 2 c 3} {if {$k == \"b\"} continue; append r $k}; set r", "ac", RetOk},
+		{"dict for {k} {a 1} {}", "must have exactly two variable names", RetError},
+		{"set d {a 1}; dict incr d a", "a 2", RetOk},
+		{"set d {a 1}; dict incr d b 5", "a 1 b 5", RetOk},
+		{"set d {a x}; dict incr d a", "expected integer but got \"x\"", RetError},
+		{"dict keys {a 1 b 2 ab 3}", "a b ab", RetOk},
+		{"dict keys {a 1 b 2 ab 3} a*", "a ab", RetOk},
+		{"dict values {a 1 b 2 c 12}", "1 2 12", RetOk},
+		{"dict values {a 1 b 2 c 12} 1*", "1 12", RetOk},
+		{"dict merge {a 1 b 2} {b 3 c 4}", "a 1 b 3 c 4", RetOk},
+		{"dict merge", "", RetOk},
+		{"set d {a 1}; dict set d b 2", "a 1 b 2", RetOk},
+		{"set d {a 1}; dict set d x y 3; dict get $d x y", "3", RetOk},
+		{"dict set d a 1; set d", "a 1", RetOk},
+		{"dict size {a 1 b 2}", "2", RetOk},
+		{"dict size {}", "0", RetOk},
+		{"set d {a 1 b 2}; dict unset d a", "b 2", RetOk},
+		{"set d {a {x 1 y 2}}; dict unset d a x", "a {y 2}", RetOk},
+		{"set d {a 1}; dict unset d z q", "key \"z\" not known in dictionary", RetError},
+		{"set d {a 1 b 2}; dict update d a x b y {incr x; unset y}; set d", "a 2", RetOk},
+		{"set d {a 1}; dict update d c z {set z 9}; set d", "a 1 c 9", RetOk},
+		{"regexp -all -inline {\\d+} \"a1b22c333\"", "1 22 333", RetOk},
+		{"regexp -all -inline {(\\d)(\\d)} \"1234\"", "{12 1 2} {34 3 4}", RetOk},
+		{"regexp -all -inline {(\\w+)} \"hello world\"", "{hello hello} {world world}", RetOk},
+		{"regexp -all -inline {\\w+} \"hello world\"", "hello world", RetOk},
+		{"regexp -inline {\\w+} \"hello world\"", "hello", RetOk},
+		{"regexp -inline {(\\d)(\\d)} \"1234\"", "12 1 2", RetOk},
+		{"regexp -all -inline {x*} \"abc\"", "{} {} {} {}", RetOk},
+		{"regexp -all {\\d} \"a1b2\"", "2", RetOk},
+		{"regexp {(\\w+) (\\w+)} \"hello world\" all first second; list $all $first $second", "{hello world} hello world", RetOk},
+		{"regexp -nocase {HELLO} \"hello\"", "1", RetOk},
+		{"regexp {z} \"hello\"", "0", RetOk},
+		{"regexp -start 4 {a.?} \"banana\" m; set m", "a", RetOk},
+		{"regexp {a.?} \"banana\" m; set m", "an", RetOk},
+		{"regexp -start 0 {a.?} \"banana\" m; set m", "an", RetOk},
+		{"regexp -start 3 -all {a} \"banana\"", "2", RetOk},
+		{"regexp -start 3 -inline {n.} \"banana\"", "na", RetOk},
+		{"regexp -start 10 {a} \"banana\"", "0", RetOk},
+		{"regexp -start 6 {a} \"banana\"", "0", RetOk},
+		{"regexp -start x {a} \"banana\"", "start option not a number", RetError},
+		{"regexp -inline {z} \"hello\" x", "regexp match variables not allowed when using -inline", RetError},
+		{"regexp -indices {l+} \"hello\" m; set m", "2 3", RetOk},
+		{"regexp -indices {(h)(z)?(e)} \"hello\" m a b c; list $m $a $b $c", "{0 1} {0 0} {-1 -1} {1 1}", RetOk},
+		{"regexp -indices -start 2 {l} \"hello\" m; set m", "2 2", RetOk},
+		{"regexp -indices -all -inline {l} \"hello\"", "{2 2} {3 3}", RetOk},
+		{"regexp {^b} \"a\\nb\"", "0", RetOk},
+		{"regexp -line {^b$} \"a\\nb\\nc\"", "1", RetOk},
+		{"regexp -line -all {^\\w} \"a\\nb\\nc\"", "3", RetOk},
+		{"string is space \" \\t\\n\"", "1", RetOk},
+		{"string is space \"\"", "1", RetOk},
+		{"string is space -strict \"\"", "0", RetOk},
+		{"string is alpha \"\"", "1", RetOk},
+		{"string is alpha -strict \"\"", "0", RetOk},
+		{"string is alpha -strict abc", "1", RetOk},
+		{"string is double 3.14", "1", RetOk},
+		{"string is double \" -1.5e3 \"", "1", RetOk},
+		{"string is double 42", "1", RetOk},
+		{"string is double 3.1x", "0", RetOk},
+		{"string is double -failindex x 3.1x; set x", "3", RetOk},
+		{"string is double -strict \"\"", "0", RetOk},
+		{"string is integer 0xFF", "1", RetOk},
+		{"string is integer -12", "1", RetOk},
+		{"string is integer 3.14", "0", RetOk},
+		{"string is integer -failindex x 3.14; set x", "1", RetOk},
+		{"string is integer -failindex x abc; set x", "0", RetOk},
+		{"string is integer -strict \"\"", "0", RetOk},
+		{"string is alpha -strict", "string is class ?-strict ?-failindex varname? string", RetError},
+		{"string is alpha -failindex", "missing failindex variable", RetError},
+		{"string is alpha -failindex i ab1c; set i", "2", RetOk},
+		{"regsub {(\\w+) (\\w+)} \"hello world\" {\\2 \\1}", "world hello", RetOk},
+		{"regsub {(\\d+)} \"abc123def\" {<\\1>}", "abc<123>def", RetOk},
+		{"regsub {b} \"abc\" {[&]}", "a[b]c", RetOk},
+		{"regsub {b} \"abc\" {\\0\\0}", "abbc", RetOk},
+		{"regsub {b} \"abc\" {\\\\}", "a\\c", RetOk},
+		{"regsub {b} \"abc\" {\\&}", "a&c", RetOk},
+		{"regsub {b} \"abcb\" {x}", "axcb", RetOk},
+		{"regsub -all {b} \"abcb\" {x}", "axcx", RetOk},
+		{"regsub -all {(a)|(b)} \"ab\" {\\2}", "b", RetOk},
+		{"regsub -all {b} \"abcb\" {x} res; set res", "axcx", RetOk},
+		{"regsub -all {b} \"abcb\" {x} res", "2", RetOk},
+		{"regsub {z} \"abc\" {x}", "abc", RetOk},
+		{"regsub -all -start 2 {b} \"abcb\" {x}", "abcx", RetOk},
+		{"regsub -start 9 {b} \"abc\" {x}", "abc", RetOk},
+		{"regsub -all -line {^} \"a\\nb\" {> }", "> a\n> b", RetOk},
+		{"regsub -all {^} \"a\\nb\" {> }", "> a\nb", RetOk},
+		{"regsub {(} \"abc\" {x}", "couldn't compile regular expression pattern: error parsing regexp: missing closing ): `(`", RetError},
+		{"proc f {a b} {expr $a+$b}; info args f", "a b", RetOk},
+		{"proc f {a b} {expr $a+$b}; info body f", "expr $a+$b", RetOk},
+		{"namespace eval ns {proc f {a} {return $a}}; info args ns::f", "a", RetOk},
+		{"namespace eval ns {proc f {a} {return $a}; info body f}", "return $a", RetOk},
+		{"info args nothere", "\"nothere\" isn't a procedure", RetError},
+		{"info body set", "\"set\" isn't a procedure", RetError},
+		{"info args", "info args procname", RetError},
+		{"unset -nocomplain x y z; info exists x", "0", RetOk},
+		{"set x 1; unset -nocomplain x; info exists x", "0", RetOk},
+		{"set a(k) 1; set a(j) 2; unset -nocomplain a(k) a(z); info exists a(k)", "0", RetOk},
+		{"set a(k) 1; set a(j) 2; unset -nocomplain a(k); set a(j)", "2", RetOk},
+		{"set x 1; unset -nocomplain -- x; info exists x", "0", RetOk},
+		{"unset missing", "", RetOk},
+		{"string is uuid 550e8400-e29b-41d4-a716-446655440000", "1", RetOk},
+		{"string is uuid 550E8400-E29B-41D4-A716-446655440000", "1", RetOk},
+		{"string is uuid 550e8400-e29b-41d4-a716-44665544000", "0", RetOk},
+		{"string is uuid 550e8400e29b-41d4-a716-446655440000", "0", RetOk},
+		{"string is uuid 550e8400-e29b-41d4-a716-44665544000g", "0", RetOk},
+		{"string is email user@example.com", "1", RetOk},
+		{"string is email first.last+tag@mail.example.org", "1", RetOk},
+		{"string is email user@example", "0", RetOk},
+		{"string is email @example.com", "0", RetOk},
+		{"string is email \"user name@example.com\"", "0", RetOk},
+		{"lsearch -nocase -glob {Foo BAR baz} \"bar\"", "1", RetOk},
+		{"lsearch -glob {Foo BAR baz} \"bar\"", "-1", RetOk},
+		{"lsearch -nocase -exact {Hello} \"hello\"", "0", RetOk},
+		{"lsearch -nocase -regexp {Foo BAR} \"^b\"", "1", RetOk},
+		{"lsearch -regexp {Foo BAR} \"^b\"", "-1", RetOk},
+		{"lsearch -nocase -all -inline {Foo BAR baz} \"B*\"", "BAR baz", RetOk},
+		{"lsearch -nocase -integer {1 2 3} 2", "1", RetOk},
+		{"lsearch -nocase {Foo bar BAZ} foo", "0", RetOk},
+		{"lsearch -nocase -all {Foo bar BAZ foo} foo", "0 3", RetOk},
+		{"lsearch {Foo bar BAZ} foo", "-1", RetOk},
+		{"lsearch -nocase {Foo bar BAZ} baz", "2", RetOk},
+		{"lsearch -bisect -integer {1 3 5 7 9} 6", "3", RetOk},
+		{"lsearch -bisect -integer {1 3 5 7 9} 5", "3", RetOk},
+		{"lsearch -bisect -integer {1 3 5 7 9} 0", "0", RetOk},
+		{"lsearch -bisect -integer {1 3 5 7 9} 10", "5", RetOk},
+		{"lsearch -bisect -integer {} 4", "0", RetOk},
+		{"lsearch -bisect {apple banana cherry} blueberry", "2", RetOk},
+		{"lsearch -bisect -nocase {apple Banana cherry} banana", "2", RetOk},
+		{"lsearch -bisect -integer {1 x 5} 3", "Not a number", RetError},
+		{"lsearch -sorted -bisect -real {1.5 2.5 3.5} 3", "2", RetOk},
+		{"lsearch -bisect -real {1.5 2.5 3.5} 2.5", "2", RetOk},
+		{"lsearch -bisect -ascii {a b d} c", "2", RetOk},
+		{"lsearch -bisect -decreasing -integer {9 7 5 3 1} 6", "2", RetOk},
+		{"lsearch -bisect -decreasing {d c a} b", "2", RetOk},
+		{"lsearch -bisect -decreasing -nocase {D c A} b", "2", RetOk},
+		{"lsearch -real {1 2.0 3} 2", "1", RetOk},
+		{"lsearch -real {1 2 3} x", "pattern not a number", RetError},
+		{"lsearch -count -all {a b a c a} a", "3", RetOk},
+		{"lsearch -count {a b a c a} a", "1", RetOk},
+		{"lsearch -count {a b c} d", "0", RetOk},
+		{"lsearch -count -all -not {a b a c a} a", "2", RetOk},
+		{"lsearch -count -all -start 1 {a b a c a} a", "2", RetOk},
+		{"string cat", "", RetOk},
+		{"string cat abc", "abc", RetOk},
+		{"string cat {a b}", "a b", RetOk},
+		{"set x \"\"; string cat $x", "", RetOk},
+		{"string cat abc {} \" d\" ef", "abc def", RetOk},
+		{"set x ab; append x cd ef", "abcdef", RetOk},
+		{"append x cd ef; set x", "cdef", RetOk},
+		{"append x", "", RetOk},
+		{"expr 2**10", "1024", RetOk},
+		{"expr 2 ** 0", "1", RetOk},
+		{"expr 2 ** -1", "0", RetOk},
+		{"expr -1 ** -3", "-1", RetOk},
+		{"expr 3 ** 39", "4052555153018976267", RetOk},
+		{"expr -2 ** 63", "-9223372036854775808", RetOk},
+		{"expr {2**63}", "integer value too large to represent", RetError},
+		{"expr 3 ** 1000000000000", "integer value too large to represent", RetError},
+		{"expr 1 ** 1000000000000", "1", RetOk},
+		{"string is entier 123456789012345678901234567890", "1", RetOk},
+		{"string is entier -42", "1", RetOk},
+		{"string is entier 12a", "0", RetOk},
+		{"string is entier 1.5", "0", RetOk},
+		{"string is entier 0x123456789abcdef0123", "1", RetOk},
+		{"string is entier -failindex x 12a; set x", "2", RetOk},
+		{"string is entier -strict \"\"", "0", RetOk},
+		{"string is wideinteger 9223372036854775807", "1", RetOk},
+		{"string is wideinteger -0x10", "1", RetOk},
+		{"string is wideinteger 9223372036854775808", "0", RetOk},
+		{"string is wideinteger -failindex x 9223372036854775808; set x", "-1", RetOk},
+		{"string is wideinteger -failindex x 12z; set x", "2", RetOk},
+		{"string is wideinteger -strict \"\"", "0", RetOk},
+		{"list a \\0 b", "a \x00 b", RetOk},
+		{"llength [list a \\0 b]", "3", RetOk},
+		{"string length [lindex [list a \\0 b] 1]", "1", RetOk},
+		{"llength [list a \\0\\0 {} b]", "4", RetOk},
+		{"string length \"a\\0b\"", "3", RetOk},
+		{"package ifneeded MyPkg 1.2 {set loaded 1.2}; package ifneeded MyPkg 1.10 {set loaded 1.10}; package require MyPkg 1.2", "1.10", RetOk},
+		{"package ifneeded MyPkg 1.2 {set loaded 1.2}; package ifneeded MyPkg 1.10 {set loaded 1.10}; package require MyPkg; set loaded", "1.10", RetOk},
+		{"package ifneeded MyPkg 1.2 {set loaded 1.2}; package ifneeded MyPkg 1.10 {set loaded 1.10}; package require -exact MyPkg 1.2; set loaded", "1.2", RetOk},
+		{"package ifneeded MyPkg 1.2 {set loaded 1.2}; package ifneeded MyPkg 2.0 {set loaded 2.0}; package require MyPkg 1.0; set loaded", "1.2", RetOk},
+		{"package ifneeded MyPkg 1.2 {set loaded 1.2}; package require MyPkg 1.3", "can't find package MyPkg 1.3", RetError},
+		{"package ifneeded MyPkg 1.2 {package provide MyPkg 1.2.1}; package require MyPkg", "1.2.1", RetOk},
+		{"package ifneeded MyPkg 1.2 {set n 1}; proc f {} {package require MyPkg}; f; package require MyPkg; set n", "1", RetOk},
+		{"package ifneeded MyPkg 1.2 {set x 1}; package ifneeded MyPkg 1.2", "set x 1", RetOk},
+		{"package ifneeded MyPkg 1.10 {}; package ifneeded MyPkg 1.9 {}; package ifneeded MyPkg 1.2 {}; package versions MyPkg", "1.2 1.9 1.10", RetOk},
+		{"package provide MyPkg 1.0; package provide MyPkg", "1.0", RetOk},
+		{"package provide MyPkg 1.0; package require MyPkg 2.0", "version conflict for package \"MyPkg\": have 1.0, need 2.0", RetError},
+		{"package ifneeded MyPkg 1.2 {error {bad load}}; package require MyPkg", "bad load", RetError},
+		{"package ifneeded MyPkg 1.x {}", "expected version number but got \"1.x\"", RetError},
+		{"package require nothere", "can't find package nothere", RetError},
+		{"array set a {x 1 y 2 xyz 3}; array unset a x*; array names a", "y", RetOk},
+		{"array set a {x 1 y 2 xyz 3}; array unset a; info exists a", "0", RetOk},
+		{"array set a {x 1 y 2 xyz 3}; array unset a q*; array size a", "3", RetOk},
+		{"array set a {x 1 y 2 xyz 3}; array names a x*", "x xyz", RetOk},
+		{"array set a {x 1 y {2 3}}; array get a", "x 1 y {2 3}", RetOk},
+		{"array set a {x 1 y 2}; set a(y)", "2", RetOk},
+		{"set a(x) 1; array exists a", "1", RetOk},
+		{"set a 1; array exists a", "0", RetOk},
+		{"set a 1; array set a {x 1}", "can't set \"a\": variable isn't array", RetError},
+		{"array set a {x}", "list must have an even number of elements", RetError},
+		{"array set a {}; array exists a", "1", RetOk},
+		{"array unset nothere", "", RetOk},
+		{"array bogus a", "array unknown subcommand bogus", RetError},
+		{"string length \"中文\"", "2", RetOk},
+		{"string bytelength \"中文\"", "6", RetOk},
+		{"string length hello", "5", RetOk},
+		{"string bytelength hello", "5", RetOk},
+		{"string bytelength", "string bytelength string", RetError},
+		{"scan \"1 2 3\" \"%d %d %d\"", "1 2 3", RetOk},
+		{"scan \"hello 42\" \"%s %d\"", "hello 42", RetOk},
+		{"scan \"abc\" \"%d\"", "{}", RetOk},
+		{"scan abc %d x", "0", RetOk},
+		{"scan {} %d", "-1", RetOk},
+		{"scan {  } %d x", "-1", RetOk},
+		{"scan \"12 abc\" \"%d %d\"", "12 {}", RetOk},
+		{"scan 12 \"%d %d %s\"", "12 {} {}", RetOk},
+		{"scan 12 \"%d %d\" a b; list $a [info exists b]", "12 0", RetOk},
+		{"scan 12 \"%d %q\"", "bad scan conversion character \"q\"", RetError},
+		{"scan \"1 2 3\" \"%d %d %d\" a b; list $a $b", "1 2", RetOk},
+		{"scan \"1 2 3\" \"%d %d %d\" a b", "2", RetOk},
+		{"scan \"12 abc\" \"%d %d\" a b", "1", RetOk},
+		{"scan \"0x1f 17 ff\" \"%i %o %x\"", "31 15 255", RetOk},
+		{"scan \"A\" \"%c\"", "65", RetOk},
+		{"scan \"3.25 abc\" \"%f %s\"", "3.25 abc", RetOk},
+		{"scan \"3 1e2\" \"%f %e\"", "3.0 100.0", RetOk},
+		{"scan \"1.5x\" \"%g%n\" v n; list $v $n", "1.5 3", RetOk},
+		{"scan \"12.345\" \"%4f\"", "12.3", RetOk},
+		{"scan abc123 {%[a-z]%d}", "abc 123", RetOk},
+		{"scan \"12345\" \"%2d%*d\"", "12", RetOk},
+		{"scan key=value {%[^=]=%s}", "key value", RetOk},
+		{"scan \"x\" \"%q\"", "bad scan conversion character \"q\"", RetError},
+		{"set ::x 5; proc f {} { set ::x }; f", "5", RetOk},
+		{"namespace eval ns {set x 1}; set ::ns::x", "1", RetOk},
+		{"set ::x 6; proc f {} { return $::x }; f", "6", RetOk},
+		{"namespace eval ns {set y 2}; set z $::ns::y", "2", RetOk},
+		{"namespace eval ns {set y 3}; set z ${::ns::y}", "3", RetOk},
+		{"set a 1; set z ${a}b", "1b", RetOk},
+		{"set a 1; set z $a:b", "1:b", RetOk},
+		{"expr [clock seconds] > 1700000000", "1", RetOk},
+		{"expr [clock milliseconds] > 1700000000000", "1", RetOk},
+		{"clock format 0 -gmt 1", "Thu Jan 01 00:00:00 UTC 1970", RetOk},
+		{"clock format 86400 -format {%Y-%m-%d %H:%M:%S %j %u %s %%} -gmt true", "1970-01-02 00:00:00 002 5 86400 %", RetOk},
+		{"clock format 0 -foo 1", "bad option \"-foo\", must be one of -format, -gmt", RetError},
+		{"clock scan {2024-03-05 10:20:30} -gmt 1", "1709634030", RetOk},
+		{"clock scan 05/03/2024 -format %d/%m/%Y -gmt 1", "1709596800", RetOk},
+		{"clock scan 10:00 -base 86400 -gmt 1", "122400", RetOk},
+		{"clock scan garbage", "unable to convert input string: \"garbage\"", RetError},
+		{"clock format [clock scan {2024-03-05 10:20:30}] -format {%Y-%m-%d %T}", "2024-03-05 10:20:30", RetOk},
+		{"clock add 0 1 day 2 hours -gmt 1", "93600", RetOk},
+		{"clock add 0 1 month -gmt 1", "2678400", RetOk},
+		{"clock add 0 1 fortnight", "unknown unit \"fortnight\", must be seconds, minutes, hours, days, weeks, months or years", RetError},
+		{"clock bogus", "clock unknown subcommand bogus", RetError},
+		{"string length [binary format a3 ab]", "3", RetOk},
+		{"binary format A4 ab", "ab  ", RetOk},
+		{"binary format a* hello", "hello", RetOk},
+		{"binary format H4 4142", "AB", RetOk},
+		{"binary format h2 14", "A", RetOk},
+		{"binary format B8 01000001", "A", RetOk},
+		{"binary format b8 10000010", "A", RetOk},
+		{"binary format c3 {65 66 67}", "ABC", RetOk},
+		{"binary format S 0x4142", "AB", RetOk},
+		{"binary format s 0x4241", "AB", RetOk},
+		{"binary format I 0x41424344", "ABCD", RetOk},
+		{"binary format i 0x41424344", "DCBA", RetOk},
+		{"binary format a1@3a1 a b", "a\x00\x00b", RetOk},
+		{"binary format a3X2a1 abc Z", "aZc", RetOk},
+		{"string length [binary format a2x2a1 ab c]", "5", RetOk},
+		{"binary format q 1", "bad field specifier \"q\"", RetError},
+		{"binary format c", "not enough arguments for all format specifiers", RetError},
+		{"binary format c 1 2", "too many arguments for all format specifiers", RetError},
+		{"binary format c abc", "expected integer but got \"abc\"", RetError},
+		{"binary format c3 {1 2}", "number of elements in list does not match count", RetError},
+		{"binary format B2 12", "expected binary string but got \"12\" instead", RetError},
+		{"binary scan ABCD I x; set x", "1094861636", RetOk},
+		{"binary scan ABCD i x; set x", "1145258561", RetOk},
+		{"binary scan [binary format c -1] c x; set x", "-1", RetOk},
+		{"binary scan [binary format c -1] cu x; set x", "255", RetOk},
+		{"binary scan [binary format S -2] S x; set x", "-2", RetOk},
+		{"binary scan [binary format W -3] W x; set x", "-3", RetOk},
+		{"binary scan [binary format d 1.5] d x; set x", "1.5", RetOk},
+		{"binary scan [binary format f 0.25] f x; set x", "0.25", RetOk},
+		{"binary scan AB c2 x; set x", "65 66", RetOk},
+		{"binary scan ABC c* x; set x", "65 66 67", RetOk},
+		{"binary scan abc a2 x; set x", "ab", RetOk},
+		{"binary scan {ab  } A* x; set x", "ab", RetOk},
+		{"binary scan ABC H* x; set x", "414243", RetOk},
+		{"binary scan A h2 x; set x", "14", RetOk},
+		{"binary scan A B* x; set x", "01000001", RetOk},
+		{"binary scan A b* x; set x", "10000010", RetOk},
+		{"binary scan abcd x2a2 x; set x", "cd", RetOk},
+		{"binary scan abcd @1a1X2a1 x y; list $x $y", "b a", RetOk},
+		{"binary scan A cc x y", "1", RetOk},
+		{"binary scan A c", "not enough arguments for all format specifiers", RetError},
+		{"binary bogus", "binary unknown subcommand bogus", RetError},
+		{"lmap x {1 2 3} {expr $x * 2}", "2 4 6", RetOk},
+		{"lmap {a b} {1 2 3 4} {list $b $a}", "{2 1} {4 3}", RetOk},
+		{"lmap x {a b} y {1 2} {set r $x$y}", "a1 b2", RetOk},
+		{"lmap x {1 2 3 4} {if {$x == 3} break; set x}", "1 2", RetOk},
+		{"lmap x {1 2 3 4} {if {$x == 2} continue; set x}", "1 3 4", RetOk},
+		{"lmap x {a b} {set r \"$x y\"}", "{a y} {b y}", RetOk},
+		{"lmap x {} {set x}", "", RetOk},
+		{"set n 0; foreach x {} {incr n}; set n", "0", RetOk},
+		{"lmap x {1 2} {error oops}", "oops", RetError},
+		{"lmap x {1 2}", "lmap varList list ?varList list ... body", RetError},
+		{"lassign {1 2 3 4} a b", "3 4", RetOk},
+		{"lassign {1 2 3 4} a b; list $a $b", "1 2", RetOk},
+		{"set a x; set b y; lassign {} a b; list $a $b", "{} {}", RetOk},
+		{"lassign {1} a b; list $a $b", "1 {}", RetOk},
+		{"lassign {1 {2 3} {4 5}} a", "{2 3} {4 5}", RetOk},
+		{"lassign {1 2}", "1 2", RetOk},
+		{"lassign", "lassign list ?varName ...", RetError},
+		{"lrepeat 3 a b", "a b a b a b", RetOk},
+		{"lrepeat 2 {a b}", "{a b} {a b}", RetOk},
+		{"lrepeat 0 a", "", RetOk},
+		{"lrepeat -1 a", "bad count \"-1\": must be integer >= 0", RetError},
+		{"lrepeat x a", "bad count \"x\": must be integer >= 0", RetError},
+		{"lrepeat 2", "lrepeat count value ?value ...", RetError},
+		{"lreverse {}", "", RetOk},
+		{"lreverse a", "a", RetOk},
+		{"lreverse {a b c}", "c b a", RetOk},
+		{"lreverse {a {b c} d}", "d {b c} a", RetOk},
+		{"lreverse {{a b} c}", "c {a b}", RetOk},
+		{"lreverse \"a   b\"", "b a", RetOk},
+		{"lreverse", "lreverse list", RetError},
 		{"string trim \"    h e l o    \"", "h e l o", RetOk},
 		{"string trimright \"    h e l o    \"", "    h e l o", RetOk},
 		{"string trimleft \"    h e l o    \"", "h e l o    ", RetOk},
@@ -267,6 +968,8 @@ func TestEval(t *testing.T) {
 			"{1 dingo} {2 banana} {0x2 carrot} {3 apple}",
 			RetOk,
 		},
+		{"proc cmp {dir a b} {expr [expr $a - $b] * $dir}; lsort -command {cmp -1} {3 1 2}", "3 2 1", RetOk},
+		{"proc cmp {a b} {string compare $a $b}; lsort -command cmp {{b c} {a d}}", "{a d} {b c}", RetOk},
 		{"set x 1; set y 2; set z 3; set a {}; if {$x==1} {set a $x}; set a", "1", RetOk},
 		{"set x 1; set y 2; set z 3; set a {};if {$x==1} {set a $x} else {set a $y}; set a", "1", RetOk},
 		{"set x 1; set y 2; set z 3; set a {};if {$x!=1} {set a $x} else {set a $y}; set a", "2", RetOk},
@@ -275,6 +978,14 @@ func TestEval(t *testing.T) {
 		{"set x 1; incr x; rename incr add1; add1 x ; set x", "3", RetOk},
 		{"set x 1; incr x; set x", "2", RetOk},
 		{"set x 1; incr x 10 ; set x", "11", RetOk},
+		{"set x 10; decr x; set x", "9", RetOk},
+		{"set x 10; decr x 5; set x", "5", RetOk},
+		{"set x 10; decr x 4", "6", RetOk},
+		{"set x 10; decr x -3; set x", "13", RetOk},
+		{"set x abc; decr x", "not a number", RetError},
+		{"set x 1; decr x abc", "decrement not a number", RetError},
+		{"decr nosuchvar", "value: nosuchvar not found", RetError},
+		{"decr", "decr varName ?decrement", RetError},
 		{"proc v {var} { upvar $var v; if [catch {set v}] {return 0} else {return 1}}; v x", "0", RetOk},
 		{"proc v {var} { upvar $var v; if [catch {set v}] {return 0} else {return 1}}; set x 1; v x", "1", RetOk},
 		{"proc foo {} {error bogus }; catch foo result", "1", RetOk},
@@ -298,3 +1009,349 @@ func TestEval(t *testing.T) {
 		}
 	}
 }
+
+func TestSetOutput(t *testing.T) {
+	var out, errOut bytes.Buffer
+	tcl := NewTCL()
+	tcl.SetOutput(&out)
+	tcl.SetErrorOutput(&errOut)
+	if tcl.EvalString("puts \"hello\"") != nil {
+		t.Error("puts returned error: " + tcl.GetResult())
+	}
+	if out.String() != "hello\n" {
+		t.Errorf("puts output wrong got: '%s'", out.String())
+	}
+	if tcl.Output() != &out || tcl.ErrorOutput() != &errOut {
+		t.Error("output writers not returned")
+	}
+}
+
+func TestTopReturn(t *testing.T) {
+	tcl := NewTCL()
+	if err := tcl.EvalString("return -code error -errorcode {MY CODE} boom"); !errors.Is(err, ErrError) || tcl.GetResult() != "boom" {
+		t.Errorf("return -code error at top level got: %v '%s'", err, tcl.GetResult())
+	}
+	if tcl.EvalString("set errorCode") != nil || tcl.GetResult() != "MY CODE" {
+		t.Errorf("return -errorcode at top level got: '%s'", tcl.GetResult())
+	}
+	if err := tcl.EvalString("return done"); err != nil || tcl.GetResult() != "done" {
+		t.Errorf("return at top level got: %v '%s'", err, tcl.GetResult())
+	}
+	if err := tcl.EvalString("proc f {} {return -level 5 -code error deep}; f"); !errors.Is(err, ErrError) || tcl.GetResult() != "deep" {
+		t.Errorf("return -level 5 at top level got: %v '%s'", err, tcl.GetResult())
+	}
+	if tcl.returning != (returnState{}) {
+		t.Errorf("return state not reset got: %v", tcl.returning)
+	}
+}
+
+func TestCommandPrefix(t *testing.T) {
+	tcl := NewTCL()
+	extA := func(t *Tcl) {
+		t.Register("hello", func(t *Tcl, _ []string) int { return t.SetResult(RetOk, "a") })
+	}
+	extB := func(t *Tcl) {
+		t.Register("hello", func(t *Tcl, _ []string) int { return t.SetResult(RetOk, "b") })
+	}
+	// Extension keeping its handle must still see the same session.
+	var saved *Tcl
+	extC := func(t *Tcl) {
+		saved = t
+		t.Register("get", func(_ *Tcl, _ []string) int { return saved.Eval("set x") })
+	}
+	tcl.AddCommandPrefix("one::", extA)
+	extB(WithPrefix(tcl, "two::"))
+	tcl.AddCommandPrefix("three::", extC)
+
+	testCases := []cases{
+		{"one::hello", "a", RetOk},
+		{"two::hello", "b", RetOk},
+		{"hello", "unable to find command: hello", RetError},
+		{"set x 1", "1", RetOk},
+		{"three::get", "1", RetOk},
+		{"proc f {} {set x 2; three::get}; f", "2", RetOk},
+	}
+	for _, test := range testCases {
+		ret := tcl.eval(test.test, parserOptions{})
+		if test.res != ret {
+			t.Errorf("Eval did not return correct results for %s, got: %d, expected %d", test.test, ret, test.res)
+		}
+		if test.match != tcl.GetResult() {
+			t.Errorf("Eval %s returned wrong result, got: '%s' expected: '%s'", test.test, tcl.GetResult(), test.match)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	tcl := NewTCL()
+	tcl.PostEvent(func(t *Tcl) int { return t.Eval("set x 1") })
+	if tcl.EvalString("update; set x") != nil || tcl.GetResult() != "1" {
+		t.Errorf("update did not run event got: '%s'", tcl.GetResult())
+	}
+
+	// Events posted from other goroutines need an active event source.
+	tcl.AddEventSource(func() bool { return true })
+	go tcl.PostEvent(func(t *Tcl) int { return t.Eval("set done 5") })
+	if tcl.EvalString("vwait done; set done") != nil || tcl.GetResult() != "5" {
+		t.Errorf("vwait did not wait for variable got: '%s'", tcl.GetResult())
+	}
+
+	// Writing same value must still end vwait.
+	go tcl.PostEvent(func(t *Tcl) int { return t.Eval("proc f {} {global done; set done 5}; f") })
+	if tcl.EvalString("vwait done; set done") != nil || tcl.GetResult() != "5" {
+		t.Errorf("vwait did not see global write got: '%s'", tcl.GetResult())
+	}
+}
+
+func TestAfter(t *testing.T) {
+	testCases := []cases{
+		{"after 10 {set a 1}; after 20 {set b 2}; after 30 {set done 1}; llength [after info]", "3", RetOk},
+		{"set id [after 10 {set a 1}]; after 20 {set done 1}; after cancel $id; after info", "after#1", RetOk},
+		{"after 10 {set a 1}; after 20 {set done 1}; after cancel set a 1; after info", "after#1", RetOk},
+		{"set id [after 1000 {set a 1}]; lindex [after info $id] 0", "set a 1", RetOk},
+		{"set id [after 1000 {set a 1}]; expr [lindex [after info $id] 1] > 900", "1", RetOk},
+		{"after info after#5", "event \"after#5\" doesn't exist", RetError},
+		{"set x 0; after idle {set x 1}; update; set x", "1", RetOk},
+		{"after 10; set x 1", "1", RetOk},
+		{"after bogus", "bad argument bogus: must be cancel, idle, info, or an integer", RetError},
+		{"after 1 {set a 1}; after 5 {set done 1}; vwait done; after info", "", RetOk},
+		{"after 1 {set a 1}; after 5 {set done 1}; vwait done; set a", "1", RetOk},
+		{"set a 0; set id [after 1 {set a 1}]; after 5 {set done 1}; after cancel $id; vwait done; set a", "0", RetOk},
+		{"after 10 {set ::done 1}; vwait ::done; set done", "1", RetOk},
+		{"namespace eval a {}; after 10 {set ::a::x 1}; vwait ::a::x; set ::a::x", "1", RetOk},
+		{"vwait nothing", "can't wait for variable \"nothing\": would wait forever", RetError},
+		{"set id [after 1000 {set done 1}]; after cancel $id; vwait done", "can't wait for variable \"done\": would wait forever", RetError},
+		{"proc p {} {after 10 {set done 1}; vwait done; return ok}; p", "ok", RetOk},
+		{"proc p {} {after 1 {set x 1}; after 5 {set done 1}; vwait done; info exists x}; p", "0", RetOk},
+	}
+
+	evalCases(t, testCases, nil)
+}
+
+func TestExec(t *testing.T) {
+	t.Setenv("TINYTCL_TEST", "x")
+	dir := t.TempDir()
+	testCases := []cases{
+		{"exec echo hello", "hello", RetOk},
+		{"exec -env {TESTVAR 42} sh -c {echo $TESTVAR}", "42", RetOk},
+		{"exec -env {TESTVAR 42} sh -c {echo $TINYTCL_TEST}", "", RetOk},
+		{"exec -envappend {FOO bar} sh -c {echo $FOO $TINYTCL_TEST}", "bar x", RetOk},
+		{"exec -envappend {TINYTCL_TEST y} sh -c {echo $TINYTCL_TEST}", "y", RetOk},
+		{"exec -env {A 1} -envappend {B 2} sh -c {echo $A$B$TINYTCL_TEST}", "12", RetOk},
+		{"exec -env {A} sh -c {echo $A}", "environment list must have name value pairs", RetError},
+		{"exec sh -c {echo oops >&2}", "oops", RetError},
+		{"exec sh -c {exit 1}", "child process exited abnormally", RetError},
+		{"exec -env", "exec ?-env list ?-envappend list ?-directory dir ?-keepnewline ?-stderrvar varName cmd ?arg ...", RetError},
+		{"exec -directory / pwd", "/", RetOk},
+		{"exec sh -c {echo out; echo err >&2} -stderrvar errout", "out", RetOk},
+		{"exec sh -c {echo out; echo err >&2} -stderrvar errout; set errout", "err", RetOk},
+		{"exec -stderrvar errout sh -c {echo err >&2}; set errout", "err", RetOk},
+		{"exec -keepnewline echo hello", "hello\n", RetOk},
+		{"exec 2>@1 sh -c {echo out; echo err >&2}", "out\nerr", RetOk},
+		{"exec sh -c {echo out; echo err >&2} >& " + dir + "/both.txt; exec cat " + dir + "/both.txt", "out\nerr", RetOk},
+		{"exec sh -c {echo out; echo err >&2} 2> " + dir + "/err.txt", "out", RetOk},
+		{"exec echo hi >" + dir + "/out.txt; exec cat " + dir + "/out.txt", "hi", RetOk},
+		{"exec echo hi >", "can't specify \">\" as last word in command", RetError},
+		{"exec -directory " + dir + " pwd", dir, RetOk},
+		{"exec echo hello | tr a-z A-Z", "HELLO", RetOk},
+		{"exec printf {b\\na\\n} | sort | head -1", "a", RetOk},
+		{"exec sh -c {echo err >&2} |& cat", "err", RetOk},
+		{"exec echo a b | wc -w > " + dir + "/count.txt; exec cat " + dir + "/count.txt", "2", RetOk},
+		{"exec echo hi | sh -c {cat >/dev/null; exit 3}", "child process exited abnormally", RetError},
+		{"catch {exec sh -c {exit 3}}; lindex $errorCode 0", "CHILDSTATUS", RetOk},
+		{"catch {exec sh -c {exit 3}}; lindex $errorCode 2", "3", RetOk},
+		{"exec echo hi |", "illegal use of | or |& in command", RetError},
+		{"exec | echo hi", "illegal use of | or |& in command", RetError},
+		{"exec nosuchcmd | cat", "couldn't execute \"nosuchcmd\": exec: \"nosuchcmd\": executable file not found in $PATH", RetError},
+		{"string is integer [exec sh -c {echo bg > " + dir + "/bg.txt} &]", "1", RetOk},
+		{"llength [exec true | true &]", "2", RetOk},
+		{"exec -directory /nonexistent ls", "couldn't execute \"ls\": chdir /nonexistent: no such file or directory", RetError},
+	}
+
+	evalCases(t, testCases, nil)
+}
+
+func BenchmarkStringCat(b *testing.B) {
+	args := []string{"string", "cat"}
+	for range 10000 {
+		args = append(args, "abcdefghij")
+	}
+	tcl := NewTCL()
+	b.ResetTimer()
+	for range b.N {
+		if stringCat(tcl, args) != RetOk || len(tcl.GetResult()) != 100000 {
+			b.Fatal("string cat returned wrong result")
+		}
+	}
+}
+
+func BenchmarkAppend(b *testing.B) {
+	args := []string{"append", "x", "abcdefghij"}
+	for range b.N {
+		tcl := NewTCL()
+		for range 10000 {
+			cmdAppend(tcl, args)
+		}
+		if len(tcl.GetResult()) != 100000 {
+			b.Fatal("append returned wrong result")
+		}
+	}
+}
+
+func TestAutoLoad(t *testing.T) {
+	dir := t.TempDir()
+	empty := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "myproc.tcl"), []byte("proc myproc {a} {return \"loaded $a\"}"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "nodef.tcl"), []byte("set x 1"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "bad.tcl"), []byte("error {load failed}"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []cases{
+		{"set auto_path {" + empty + " " + dir + "}; myproc 1", "loaded 1", RetOk},
+		{"set auto_path " + dir + "; proc f {} {myproc 2}; f", "loaded 2", RetOk},
+		{"set auto_path " + dir + "; proc f {} {catch nodef}; f; set x", "1", RetOk},
+		{"myproc 1", "unable to find command: myproc", RetError},
+		{"set auto_path " + empty + "; myproc 1", "unable to find command: myproc", RetError},
+		{"set auto_path " + dir + "; nodef", "unable to find command: nodef", RetError},
+		{"set auto_path " + dir + "; bad", "load failed", RetError},
+	}
+
+	evalCases(t, testCases, nil)
+}
+
+func TestPackageIndex(t *testing.T) {
+	dir := t.TempDir()
+	bad := t.TempDir()
+	err := os.Mkdir(filepath.Join(dir, "mypkg"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "mypkg", "pkgIndex.tcl"),
+		[]byte("package ifneeded mypkg 1.0 \"proc mycmd {} {return ok}; set pkgdir $dir\""), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "pkgIndex.tcl"), []byte("package ifneeded toppkg 2.0 {set top 1}"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(bad, "pkgIndex.tcl"), []byte("error {index failed}"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []cases{
+		{"set auto_path " + dir + "; package require mypkg", "1.0", RetOk},
+		{"set auto_path " + dir + "; package require mypkg; mycmd", "ok", RetOk},
+		{"set auto_path " + dir + "; package require mypkg; set pkgdir", filepath.Join(dir, "mypkg"), RetOk},
+		{"set auto_path " + dir + "; proc f {} {package require toppkg}; f", "2.0", RetOk},
+		{"set auto_path " + dir + "; package require toppkg; info exists dir", "0", RetOk},
+		{"set auto_path " + dir + "; package require mypkg 2.0", "can't find package mypkg 2.0", RetError},
+		{"set auto_path " + bad + "; package require mypkg", "index failed", RetError},
+		{"package require mypkg", "can't find package mypkg", RetError},
+	}
+
+	evalCases(t, testCases, nil)
+}
+
+func TestBigInteger(t *testing.T) {
+	testCases := []cases{
+		{"expr {2**63}", "9223372036854775808", RetOk},
+		{"expr {2**100}", "1267650600228229401496703205376", RetOk},
+		{"expr 9223372036854775807 + 1", "9223372036854775808", RetOk},
+		{"expr 100000000000000000000 * -3", "-300000000000000000000", RetOk},
+		{"expr 100000000000000000000 / 7", "14285714285714285714", RetOk},
+		{"expr 100000000000000000000 > 99999999999999999999", "1", RetOk},
+		{"expr 0x10 max 010", "16", RetOk},
+		{"expr 2 ** -2", "0", RetOk},
+		{"expr 2 ** 100000000", "exponent too large", RetError},
+		{"expr -1 ** 100000000001", "-1", RetOk},
+		{"expr 5 / 0", "divide by zero", RetError},
+		{"expr -5", "-5", RetOk},
+	}
+
+	evalCases(t, testCases, func(tcl *Tcl) { tcl.EnableBigIntegers(true) })
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("TINYTCL_ENV", "value")
+
+	testCases := []cases{
+		{"set env(TINYTCL_ENV)", "value", RetOk},
+		{"proc f {} {global env; return $env(TINYTCL_ENV)}; f", "value", RetOk},
+		{"set ::env(TINYTCL_ENV)", "value", RetOk},
+		{"array names env TINYTCL_*", "TINYTCL_ENV", RetOk},
+		{"array get env TINYTCL_ENV", "TINYTCL_ENV value", RetOk},
+		{"info exists env(TINYTCL_NONE)", "0", RetOk},
+		{"set env(TINYTCL_NONE)", "can't read \"env(TINYTCL_NONE)\": no such variable", RetError},
+		{"set env(TINYTCL_NEW) 1; exec sh -c {echo $TINYTCL_NEW}", "1", RetOk},
+		{"set env(TINYTCL_NEW) 2; unset env(TINYTCL_NEW); array names env TINYTCL_NEW", "", RetOk},
+		{"set env(TINYTCL_NEW) 3; array unset env TINYTCL_N*; info exists env(TINYTCL_NEW)", "0", RetOk},
+	}
+
+	evalCases(t, testCases, nil)
+
+	if os.Getenv("TINYTCL_NEW") != "" {
+		t.Error("env variable not removed from environment")
+	}
+
+	// Unsetting all of env must leave it linked to the environment.
+	saved := os.Environ()
+	tcl := NewTCL()
+	ret := tcl.eval("array unset env; set env(TINYTCL_AFTER) 4; array names env", parserOptions{})
+	names := tcl.GetResult()
+	value := os.Getenv("TINYTCL_AFTER")
+	os.Clearenv()
+	for _, env := range saved {
+		name, val, _ := strings.Cut(env, "=")
+		os.Setenv(name, val)
+	}
+	if ret != RetOk || names != "TINYTCL_AFTER" || value != "4" {
+		t.Errorf("env not linked after array unset got: '%s' '%s'", names, value)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	testCases := []cases{
+		{"set ops {}; proc log {n1 n2 op} {global ops; lappend ops $n1 $n2 $op}; set x 1; trace add variable x write log; set x 2; set ops", "x {} write", RetOk},
+		{"set ops {}; proc log {n1 n2 op} {global ops; lappend ops $op}; set x 1; trace add variable x {read write unset} log; set y $x; incr x; unset x; set ops", "read read write unset", RetOk},
+		{"set ops {}; proc log {n1 n2 op} {global ops; lappend ops $n1 $n2 $op}; array set a {}; trace add variable a write log; set a(k) 1; set ops", "a k write", RetOk},
+		{"set ops {}; proc log {n1 n2 op} {global ops; lappend ops $n2}; set a(j) 1; trace add variable a(k) write log; set a(j) 2; set a(k) 3; set ops", "k", RetOk},
+		{"proc double {n1 n2 op} {upvar $n1 v; set v [expr {$v * 2}]}; trace add variable x write double; set x 4", "8", RetOk},
+		{"proc ro {n1 n2 op} {error readonly}; set x 1; trace add variable x write ro; set x 2", "can't set \"x\": readonly", RetError},
+		{"proc cnt {n1 n2 op} {upvar $n1 v; incr v}; set x 0; trace add variable x read cnt; list $x $x", "1 2", RetOk},
+		{"set ops {}; proc f {} {global g; set g 5}; proc log {n1 n2 op} {global ops; lappend ops $n1}; trace add variable g write log; f; set ops", "g", RetOk},
+		{"proc log {n1 n2 op} {}; trace add variable x {read write} log; trace info variable x", "{{read write} log}", RetOk},
+		{"proc log {n1 n2 op} {}; trace add variable x {read write} log; trace remove variable x {write read} log; trace info variable x", "", RetOk},
+		{"set ops {}; proc log {n1 n2 op} {global ops; lappend ops $op}; set x 1; trace add variable x write log; trace remove variable x write log; set x 2; set ops", "", RetOk},
+		{"trace add variable x bogus log", "bad operation list \"bogus\": must be one or more of read, unset, write", RetError},
+		{"trace add variable x {} log", "bad operation list \"\": must be one or more of read, unset, write", RetError},
+		{"trace add bogus x write log", "bad option \"bogus\": must be command or variable", RetError},
+		{"trace bogus variable x", "trace unknown subcommand bogus", RetError},
+		{"trace add variable x write", "trace add variable name ops script", RetError},
+		{"set ops {}; proc f {} {}; proc log {old new op} {global ops; lappend ops $old $new $op}; trace add command f rename log; rename f g; set ops", "f g rename", RetOk},
+		{"set ops {}; proc f {} {}; proc log {old new op} {global ops; lappend ops $old $new $op}; trace add command f {rename delete} log; rename f g; rename g {}; set ops", "f g rename g {} delete", RetOk},
+		{"proc f {} {}; trace add command f delete log; trace info command f", "{delete log}", RetOk},
+		{"set ops {}; proc f {} {}; proc log {old new op} {global ops; lappend ops $old $new $op}; trace add command f delete log; proc f {} {}; set ops", "f {} delete", RetOk},
+		{"proc log {n1 n2 op} {}; trace add variable x write log; info exists x", "0", RetOk},
+		{"proc log {n1 n2 op} {}; trace add variable x write log; set x", "value: x not found", RetError},
+		{"proc log {n1 n2 op} {}; trace add variable x write log; info vars x", "", RetOk},
+		{"set ops {}; proc log {n1 n2 op} {global ops; lappend ops $op}; trace add variable x write log; set x 1; list [info exists x] $ops", "1 write", RetOk},
+		{"proc log {n1 n2 op} {}; trace add variable a(k) write log; array exists a", "0", RetOk},
+		{"proc log {n1 n2 op} {}; set a(j) 1; trace add variable a(k) write log; list [array size a] [array names a] [info exists a(k)]", "1 j 0", RetOk},
+		{"set n 0; set x 1; proc log {n1 n2 op} {incr ::n}; trace add variable x read log; info exists x; set n", "0", RetOk},
+		{"set a(k) 1; proc log {n1 n2 op} {error boom}; trace add variable a read log; info exists a(k)", "1", RetOk},
+		{"trace add command nothere delete log", "unknown command \"nothere\"", RetError},
+		{"proc f {} {}; trace add command f write log", "bad operation list \"write\": must be one or more of delete, rename", RetError},
+	}
+
+	evalCases(t, testCases, nil)
+}
@@ -0,0 +1,226 @@
+/*
+ * TCL  trace command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tcl
+
+import (
+	"slices"
+	"strings"
+)
+
+// Script run when traced variable or command is used.
+type traceEntry struct {
+	ops    []string // Operations which run script.
+	script string   // Command prefix, called with names and operation.
+}
+
+var traceMap = map[string]func(*Tcl, []string) int{
+	"add":    traceAdd,    // type name ops script
+	"info":   traceInfo,   // type name
+	"remove": traceRemove, // type name ops script
+}
+
+// Operations which can be traced.
+var traceOps = map[string][]string{
+	"command":  {"delete", "rename"},
+	"variable": {"read", "unset", "write"},
+}
+
+// Trace command.
+func cmdTrace(tcl *Tcl, args []string) int {
+	if len(args) < 2 {
+		return tcl.SetResult(RetError, "trace subcommand ?arg ...")
+	}
+	fn, ok := traceMap[args[1]]
+	if !ok {
+		return tcl.SetResult(RetError, "trace unknown subcommand "+args[1])
+	}
+	if len(args) < 3 {
+		return tcl.SetResult(RetError, "trace "+args[1]+" type ?arg ...")
+	}
+	if _, ok := traceOps[args[2]]; !ok {
+		return tcl.SetResult(RetError, "bad option \""+args[2]+"\": must be command or variable")
+	}
+	return fn(tcl, args)
+}
+
+// Return list of operations, or error message if not valid for type.
+func (tcl *Tcl) traceOpList(typ string, ops string) ([]string, string) {
+	valid := traceOps[typ]
+	msg := "bad operation list \"" + ops + "\": must be one or more of " + strings.Join(valid, ", ")
+	if strings.TrimSpace(ops) == "" {
+		return nil, msg
+	}
+	list := tcl.ParseArgs(ops)
+	for _, op := range list {
+		if !slices.Contains(valid, op) {
+			return nil, msg
+		}
+	}
+	return list, ""
+}
+
+// Return pointer to traces of variable or command, nil if not found. If
+// create is set, a variable which does not exist is made as an undefined
+// placeholder to hold the traces.
+func (tcl *Tcl) traceList(typ string, name string, create bool) *[]traceEntry {
+	if typ == "command" {
+		cmd, ok := tcl.findCommand(name)
+		if !ok || cmd == nil {
+			return nil
+		}
+		return &cmd.traces
+	}
+
+	arrayName, index, isArray := splitArrayName(name)
+	vars, base := tcl.varTable(arrayName)
+	if vars == nil {
+		return nil
+	}
+	variable, ok := vars[base]
+	if !ok {
+		if !create {
+			return nil
+		}
+		variable = &tclVar{undefined: true}
+		if isArray {
+			variable.array = make(map[string]*tclVar)
+		}
+		vars[base] = variable
+	}
+	if !isArray {
+		return &variable.traces
+	}
+	if variable.array == nil {
+		return nil
+	}
+	element, ok := variable.array[index]
+	if !ok {
+		if !create {
+			return nil
+		}
+		element = &tclVar{undefined: true}
+		variable.array[index] = element
+	}
+	return &element.traces
+}
+
+// Add trace to variable or command.
+func traceAdd(tcl *Tcl, args []string) int {
+	if len(args) != 6 {
+		return tcl.SetResult(RetError, "trace add "+args[2]+" name ops script")
+	}
+	ops, msg := tcl.traceOpList(args[2], args[4])
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+	traces := tcl.traceList(args[2], args[3], true)
+	if traces == nil {
+		return tcl.SetResult(RetError, "unknown "+args[2]+" \""+args[3]+"\"")
+	}
+	*traces = append(*traces, traceEntry{ops: ops, script: args[5]})
+	return tcl.SetResult(RetOk, "")
+}
+
+// Remove trace with same operations and script.
+func traceRemove(tcl *Tcl, args []string) int {
+	if len(args) != 6 {
+		return tcl.SetResult(RetError, "trace remove "+args[2]+" name ops script")
+	}
+	ops, msg := tcl.traceOpList(args[2], args[4])
+	if msg != "" {
+		return tcl.SetResult(RetError, msg)
+	}
+	traces := tcl.traceList(args[2], args[3], false)
+	if traces == nil {
+		return tcl.SetResult(RetOk, "")
+	}
+	for i, trace := range *traces {
+		if trace.script == args[5] && sameOps(trace.ops, ops) {
+			*traces = slices.Delete(*traces, i, i+1)
+			break
+		}
+	}
+	return tcl.SetResult(RetOk, "")
+}
+
+// Return list of operations and script of each trace.
+func traceInfo(tcl *Tcl, args []string) int {
+	if len(args) != 4 {
+		return tcl.SetResult(RetError, "trace info "+args[2]+" name")
+	}
+	traces := tcl.traceList(args[2], args[3], false)
+	if traces == nil {
+		return tcl.SetResult(RetOk, "")
+	}
+	list := []string{}
+	for _, trace := range *traces {
+		list = append(list, escapeList([]string{escapeList(trace.ops), trace.script}))
+	}
+	return tcl.SetResult(RetOk, escapeList(list))
+}
+
+// Return true if both lists hold the same operations.
+func sameOps(a []string, b []string) bool {
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// Run traces for operation, script is called with arguments added. Result of
+// interpreter is kept unless a trace returns an error.
+func (tcl *Tcl) runTraces(traces []traceEntry, op string, args ...string) (int, string) {
+	result := tcl.result
+	call := escapeList(append(args, op))
+	for _, trace := range slices.Clone(traces) {
+		if !slices.Contains(trace.ops, op) {
+			continue
+		}
+		if ret := tcl.eval(trace.script+" "+call, parserOptions{}); ret == RetError {
+			return RetError, tcl.result
+		}
+	}
+	tcl.result = result
+	return RetOk, ""
+}
+
+// Run traces of variable, and of element of array if index is given.
+// Traces are not run again while a trace of the variable is running.
+func (tcl *Tcl) traceVar(variable *tclVar, element *tclVar, name string, index string, op string) (int, string) {
+	for _, v := range []*tclVar{variable, element} {
+		if v == nil || len(v.traces) == 0 || v.tracing {
+			continue
+		}
+		v.tracing = true
+		ret, msg := tcl.runTraces(v.traces, op, name, index)
+		v.tracing = false
+		if ret != RetOk {
+			return ret, msg
+		}
+	}
+	return RetOk, ""
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+/*
+ * TCL  file access checks on Unix.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tclfile
+
+import (
+	"io/fs"
+
+	"golang.org/x/sys/unix"
+)
+
+// Return true if current user has all of the permissions to file.
+func canAccess(name string, _ fs.FileInfo, perm fs.FileMode) bool {
+	mode := uint32(0)
+	if perm&0o444 != 0 {
+		mode |= unix.R_OK
+	}
+	if perm&0o222 != 0 {
+		mode |= unix.W_OK
+	}
+	if perm&0o111 != 0 {
+		mode |= unix.X_OK
+	}
+	return unix.Access(name, mode) == nil
+}
@@ -0,0 +1,430 @@
+/*
+ * TCL  chan and fconfigure commands.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tclfile
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tcl "github.com/rcornwell/tinyTCL/tcl"
+)
+
+// Reader which collects input in background, so reads can be done
+// without waiting.
+type asyncReader struct {
+	lock   sync.Mutex
+	ready  *sync.Cond
+	buffer []byte // Input not yet read.
+	err    error  // Error from source, io.EOF at end of input.
+	notify func() // Called when input arrives, nil if not watched.
+}
+
+// How often channel events are checked again while channel stays ready.
+const eventPoll = 10 * time.Millisecond
+
+var chanMap = map[string]func(*tcl.Tcl, []string) int{
+	"close":     cmdClose,
+	"configure": cmdFConfigure,
+	"eof":       cmdEOF,
+	"event":     chanEvent,
+	"flush":     cmdFlush,
+	"gets":      cmdGets,
+	"puts":      cmdPuts,
+	"read":      cmdRead,
+	"seek":      func(t *tcl.Tcl, a []string) int { return cmdSeek(t, a, "seek") },
+	"tell":      func(t *tcl.Tcl, a []string) int { return cmdSeek(t, a, "tell") },
+}
+
+// Start collecting input from source.
+func newAsyncReader(source io.Reader) *asyncReader {
+	r := &asyncReader{}
+	r.ready = sync.NewCond(&r.lock)
+	go r.fill(source)
+	return r
+}
+
+// Copy input from source into buffer until error or end of input.
+func (r *asyncReader) fill(source io.Reader) {
+	input := make([]byte, 4096)
+	for {
+		n, err := source.Read(input)
+		r.lock.Lock()
+		r.buffer = append(r.buffer, input[:n]...)
+		if err != nil {
+			r.err = err
+		}
+		r.ready.Broadcast()
+		if r.notify != nil {
+			r.notify()
+		}
+		r.lock.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read input, waits until some input is available.
+func (r *asyncReader) Read(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for len(r.buffer) == 0 && r.err == nil {
+		r.ready.Wait()
+	}
+	if len(r.buffer) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
+
+// Call fn when input arrives, or now if input is waiting. Nil stops calls.
+func (r *asyncReader) watch(fn func()) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.notify = fn
+	if fn != nil && (len(r.buffer) != 0 || r.err != nil) {
+		fn()
+	}
+}
+
+// Check if input can be read without waiting.
+func (r *asyncReader) hasInput() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.buffer) != 0 || r.err != nil
+}
+
+// Return up to size bytes of input without waiting, all input if size < 0.
+// Also returns true if at end of input.
+func (r *asyncReader) available(size int) ([]byte, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if size < 0 || size > len(r.buffer) {
+		size = len(r.buffer)
+	}
+	data := append([]byte{}, r.buffer[:size]...)
+	r.buffer = r.buffer[size:]
+	return data, len(r.buffer) == 0 && r.err != nil
+}
+
+// Return next line without waiting. Returns false if a full line is not
+// available, at end of input any partial line is returned.
+func (r *asyncReader) line() (string, bool, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if pos := bytes.IndexByte(r.buffer, '\n'); pos >= 0 {
+		line := string(r.buffer[:pos])
+		r.buffer = r.buffer[pos+1:]
+		return line, true, false
+	}
+	if r.err == nil {
+		return "", false, false
+	}
+	line := string(r.buffer)
+	r.buffer = nil
+	return line, line != "", true
+}
+
+// Channel commands.
+func cmdChan(t *tcl.Tcl, args []string) int {
+	if len(args) < 2 {
+		return t.SetResult(tcl.RetError, "chan subcommand ?arg ...")
+	}
+	fn, ok := chanMap[args[1]]
+	if !ok {
+		return t.SetResult(tcl.RetError, "chan unknown subcommand "+args[1])
+	}
+	return fn(t, args[1:])
+}
+
+// Set or query channel options.
+func cmdFConfigure(t *tcl.Tcl, args []string) int {
+	if len(args) < 2 {
+		return t.SetResult(tcl.RetError, "fconfigure channel ?option ?value ?option value ...")
+	}
+
+	files, ok := t.Data["file"].(*tclFileData)
+	if !ok {
+		panic("invalid data type file extension")
+	}
+
+	ch, ok := files.channels[args[1]]
+	if !ok {
+		return t.SetResult(tcl.RetError, "file "+args[1]+" not opened")
+	}
+
+	// Query all options.
+	if len(args) == 2 {
+		list := []string{}
+		for _, option := range channelOptions {
+			value, _ := ch.getOption(option)
+			list = append(list, option, value)
+		}
+		return t.SetResult(tcl.RetOk, strings.Join(list, " "))
+	}
+
+	// Query a single option.
+	if len(args) == 3 {
+		value, ok := ch.getOption(args[2])
+		if !ok {
+			return t.SetResult(tcl.RetError, "bad option "+args[2])
+		}
+		return t.SetResult(tcl.RetOk, value)
+	}
+
+	if len(args)%2 != 0 {
+		return t.SetResult(tcl.RetError, "missing value for option "+args[len(args)-1])
+	}
+	for i := 2; i < len(args); i += 2 {
+		if msg := ch.setOption(args[i], args[i+1]); msg != "" {
+			return t.SetResult(tcl.RetError, msg)
+		}
+	}
+	return t.SetResult(tcl.RetOk, "")
+}
+
+// Options reported by fconfigure.
+var channelOptions = []string{"-blocking", "-buffering", "-buffersize", "-encoding", "-translation"}
+
+// Default size of output buffer.
+const defaultBufferSize = 4096
+
+// Return size of output buffer.
+func (config *tclChannelConfig) size() int {
+	if config.bufferSize == 0 {
+		return defaultBufferSize
+	}
+	return config.bufferSize
+}
+
+// Return current value of channel option.
+func (ch *tclChannel) getOption(option string) (string, bool) {
+	switch option {
+	case "-blocking":
+		return boolString(!ch.config.nonBlocking), true
+	case "-buffering":
+		return bufferModes[ch.config.buffering], true
+	case "-buffersize":
+		return tcl.ConvertNumberToString(ch.config.size(), 10), true
+	case "-encoding":
+		return encodingNames[ch.config.encoding], true
+	case "-translation":
+		return translationModes[ch.config.translation], true
+	}
+	return "", false
+}
+
+// Set channel option, returns error message if option or value is not valid.
+func (ch *tclChannel) setOption(option string, value string) string {
+	switch option {
+	case "-blocking":
+		blocking, ok := parseBool(value)
+		if !ok {
+			return "expected boolean but got \"" + value + "\""
+		}
+		ch.setBlocking(blocking)
+	case "-buffering":
+		mode := slices.Index(bufferModes, value)
+		if mode < 0 {
+			return "bad value for -buffering: must be one of full, line, or none"
+		}
+		if err := ch.flush(); err != nil {
+			return err.Error()
+		}
+		ch.config.buffering = mode
+	case "-buffersize":
+		size, _, ok := tcl.ConvertStringToNumber(value, 10, 0)
+		if !ok || size <= 0 {
+			return "expected positive integer but got \"" + value + "\""
+		}
+		if err := ch.flush(); err != nil {
+			return err.Error()
+		}
+		// New buffer is created on next write.
+		ch.buffer = nil
+		ch.config.bufferSize = size
+	case "-encoding":
+		encoding := slices.Index(encodingNames, strings.ToLower(value))
+		if encoding < 0 {
+			return "unknown encoding \"" + value + "\""
+		}
+		if err := ch.flush(); err != nil {
+			return err.Error()
+		}
+		ch.config.encoding = encoding
+	case "-translation":
+		// Binary is lf translation with binary encoding.
+		if value == "binary" {
+			if err := ch.flush(); err != nil {
+				return err.Error()
+			}
+			ch.config.translation = translateLF
+			ch.config.encoding = encodingBinary
+			break
+		}
+		mode := slices.Index(translationModes, value)
+		if mode < 0 {
+			return "bad value for -translation: must be one of auto, binary, cr, lf, or crlf"
+		}
+		if err := ch.flush(); err != nil {
+			return err.Error()
+		}
+		ch.config.translation = mode
+	default:
+		return "bad option " + option
+	}
+	return ""
+}
+
+// Set channel blocking mode. Non-blocking channels collect input in the
+// background.
+func (ch *tclChannel) setBlocking(blocking bool) {
+	ch.config.nonBlocking = !blocking
+	if !blocking {
+		ch.startAsync()
+	}
+}
+
+// Start collecting input in the background.
+func (ch *tclChannel) startAsync() {
+	if ch.async == nil && ch.reader != nil {
+		ch.async = newAsyncReader(ch.reader)
+		ch.reader = ch.async
+	}
+}
+
+// Set, remove or return script run when channel is readable or writable.
+func chanEvent(t *tcl.Tcl, args []string) int {
+	if len(args) != 3 && len(args) != 4 {
+		return t.SetResult(tcl.RetError, "chan event channel event ?script")
+	}
+
+	files, ok := t.Data["file"].(*tclFileData)
+	if !ok {
+		panic("invalid data type file extension")
+	}
+
+	name := args[1]
+	ch, ok := files.channels[name]
+	if !ok {
+		return t.SetResult(tcl.RetError, "file "+name+" not opened")
+	}
+
+	switch args[2] {
+	case "readable":
+		if len(args) == 3 {
+			return t.SetResult(tcl.RetOk, ch.readScript)
+		}
+		if ch.reader == nil {
+			return t.SetResult(tcl.RetError, "channel "+name+" wasn't opened for reading")
+		}
+		ch.readScript = args[3]
+		if ch.readScript == "" {
+			if ch.async != nil {
+				ch.async.watch(nil)
+			}
+			break
+		}
+		ch.startAsync()
+		ch.async.watch(func() { t.PostEvent(ch.readEvent(files, name)) })
+
+	case "writable":
+		if len(args) == 3 {
+			return t.SetResult(tcl.RetOk, ch.writeScript)
+		}
+		if ch.output(t) == nil {
+			return t.SetResult(tcl.RetError, "channel "+name+" wasn't opened for writing")
+		}
+		ch.writeScript = args[3]
+		if ch.writeScript != "" && !ch.writeWatch {
+			ch.writeWatch = true
+			t.PostEvent(ch.writeEvent(files, name))
+		}
+
+	default:
+		return t.SetResult(tcl.RetError, "bad event name \""+args[2]+"\": must be readable or writable")
+	}
+	return t.SetResult(tcl.RetOk, "")
+}
+
+// Return event which runs readable script, if channel has input.
+func (ch *tclChannel) readEvent(files *tclFileData, name string) func(*tcl.Tcl) int {
+	var event func(*tcl.Tcl) int
+	event = func(t *tcl.Tcl) int {
+		if files.channels[name] != ch || ch.readScript == "" || !ch.async.hasInput() {
+			return tcl.RetOk
+		}
+		ret := t.EvalGlobal(ch.readScript)
+
+		// Run again later while input remains.
+		if files.channels[name] == ch && ch.readScript != "" && ch.async.hasInput() {
+			time.AfterFunc(eventPoll, func() { t.PostEvent(event) })
+		}
+		return ret
+	}
+	return event
+}
+
+// Return event which runs writable script, channels are always writable so
+// script is run until it is removed.
+func (ch *tclChannel) writeEvent(files *tclFileData, name string) func(*tcl.Tcl) int {
+	var event func(*tcl.Tcl) int
+	event = func(t *tcl.Tcl) int {
+		if files.channels[name] != ch || ch.writeScript == "" {
+			ch.writeWatch = false
+			return tcl.RetOk
+		}
+		ret := t.EvalGlobal(ch.writeScript)
+		time.AfterFunc(eventPoll, func() { t.PostEvent(event) })
+		return ret
+	}
+	return event
+}
+
+// Convert a TCL boolean value.
+func parseBool(str string) (bool, bool) {
+	switch str {
+	case "1", "yes", "true", "on":
+		return true, true
+	case "0", "no", "false", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// Convert boolean to TCL value.
+func boolString(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
@@ -26,12 +26,18 @@
 package tclfile
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	tcl "github.com/rcornwell/tinyTCL/tcl"
 )
@@ -42,6 +48,34 @@ type cases struct {
 	res   int
 }
 
+// Evaluate each test case in a new interpreter with the file extension,
+// cleanup is evaluated after each test if given.
+func evalCases(t *testing.T, testCases []cases, cleanup string) {
+	t.Helper()
+	for _, test := range testCases {
+		tc := tcl.NewTCL()
+		Init(tc)
+		ret := tc.EvalString(test.test)
+		switch test.res {
+		case tcl.RetOk:
+			if ret != nil {
+				t.Errorf("Eval did not return correct results for expected: '%s' got: '%s'", test.match, ret.Error())
+			}
+			if test.match != tc.GetResult() {
+				t.Errorf("Eval returned wrong result, got: '%s' expected: '%s'", tc.GetResult(), test.match)
+			}
+
+		case tcl.RetError:
+			if ret == nil {
+				t.Error("Eval did not return error as expected", test.test)
+			}
+		}
+		if cleanup != "" {
+			_ = tc.EvalString(cleanup)
+		}
+	}
+}
+
 func TestFileOps(t *testing.T) {
 	// Create a test file.
 	tmp, err := os.MkdirTemp("/tmp", "")
@@ -62,6 +96,16 @@ func TestFileOps(t *testing.T) {
 	f.Close()
 	base := filepath.Base(name)
 
+	// Give file known time and permissions for copy -preserve.
+	stamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(name, stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(name, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := strconv.FormatInt(stamp.Unix(), 10)
+
 	testCases := []cases{
 		{"file exists " + name, "1", tcl.RetOk},
 		{"file size " + name, "3950", tcl.RetOk},
@@ -86,6 +130,18 @@ func TestFileOps(t *testing.T) {
 		{"file dir " + tmp, base + " x", tcl.RetOk},
 		{"file cwd " + tmp + "/x; file rename " + base + " " + base + "2 ; file dir", base + "2", tcl.RetOk},
 		{"file cwd " + tmp + "/x; file delete " + base + "2 ; file exists " + base + "2", "0", tcl.RetOk},
+		{"file copy -preserve " + name + " " + tmp + "/x/p1; file mtime " + tmp + "/x/p1", mtime, tcl.RetOk},
+		{"file copy -preserve " + name + " " + tmp + "/x/p2; file executable " + tmp + "/x/p2", "1", tcl.RetOk},
+		{"file copy " + name + " " + tmp + "/x/p3; string equal [file mtime " + tmp + "/x/p3] " + mtime, "0", tcl.RetOk},
+		{"file copy -force -preserve " + name + " " + tmp + "/x/p1; file mtime " + tmp + "/x/p1", mtime, tcl.RetOk},
+		{"file copy -preserve " + name + " " + tmp + "/x/p1", "", tcl.RetError},
+		{"file mtime " + tmp + "/x/p3 1700000000; file mtime " + tmp + "/x/p3", "1700000000", tcl.RetOk},
+		{"file atime " + tmp + "/x/p3 1600000000; file mtime " + tmp + "/x/p3", "1700000000", tcl.RetOk},
+		{"file atime " + tmp + "/x/p3 1600000000; file atime " + tmp + "/x/p3", "1600000000", tcl.RetOk},
+		{"file atime " + tmp + "/x/p3 1600000000; file copy -preserve " + tmp + "/x/p3 " + tmp + "/x/p4; file atime " + tmp + "/x/p4", "1600000000", tcl.RetOk},
+		{"file mtime " + tmp + "/x/p3 bad", "", tcl.RetError},
+		{"file mtime " + tmp + "/x/none 1700000000", "", tcl.RetError},
+		{"file size " + name + " 10", "", tcl.RetError},
 	}
 
 	for _, test := range testCases {
@@ -130,6 +186,13 @@ func TestFileRead(t *testing.T) {
 
 	testCases := []cases{
 		{"open " + name + "; lsort [file channels] ", "file7 stderr stdin stdout", tcl.RetOk},
+		{"lrange [file channels] 0 2", "stdin stdout stderr", tcl.RetOk},
+		{"file channels std*", "stdin stdout stderr", tcl.RetOk},
+		{"file channels stdo*", "stdout", tcl.RetOk},
+		{"close stdin", "", tcl.RetError},
+		{"close stdout", "", tcl.RetError},
+		{"close stderr write", "", tcl.RetError},
+		{"catch {close stdin}; lrange [file channels] 0 2", "stdin stdout stderr", tcl.RetOk},
 		{
 			"set fd [open " + name + "] ; gets $fd ",
 			"00000 ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
@@ -142,6 +205,12 @@ func TestFileRead(t *testing.T) {
 		},
 		{"set fd [open " + name + "] ; read $fd 78; tell $fd", "78", tcl.RetOk},
 		{"set fd [open " + name + "] ; seek $fd 80; tell $fd", "80", tcl.RetOk},
+		{"tell stdin", "-1", tcl.RetOk},
+		{"tell stdout", "-1", tcl.RetOk},
+		{"tell stderr", "-1", tcl.RetOk},
+		{"set fd [open " + name + "] ; tell $fd", "0", tcl.RetOk},
+		{"seek stdin 0", "", tcl.RetError},
+		{"tell", "", tcl.RetError},
 		{"set fd [open " + name + "] ; seek $fd 80; seek $fd 80 current ; tell $fd", "160", tcl.RetOk},
 		{
 			"set fd [open " + name + "] ; seek $fd 158; gets $fd",
@@ -153,6 +222,12 @@ func TestFileRead(t *testing.T) {
 			"00049 ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
 			tcl.RetOk,
 		},
+		{"set fd [open " + name + "] ; seek $fd 0 end; list [gets $fd line] $line [eof $fd]", "-1 {} 1", tcl.RetOk},
+		{"set fd [open " + name + "] ; seek $fd 0 end; list [gets $fd] [eof $fd]", "{} 1", tcl.RetOk},
+		{"set fd [open " + name + "] ; seek $fd -1 end; list [gets $fd line] [gets $fd line]", "0 -1", tcl.RetOk},
+		{"set fd [open " + name + "] ; seek $fd -10 end; gets $fd line; list [gets $fd line] $line", "-1 {}", tcl.RetOk},
+		{"set fd [open " + name + "] ; seek $fd -10 end; read $fd 3; list [gets $fd line] $line", "6 456789", tcl.RetOk},
+		{"set fd [open " + name + "] ; set n 0; while {[gets $fd line] >= 0} {incr n}; set n", "50", tcl.RetOk},
 	}
 
 	for _, test := range testCases {
@@ -306,7 +381,7 @@ func TestFileWrite(t *testing.T) {
 		t.Error("Unable to source file " + name)
 		return
 	}
-	if tc2.GetResult() != "" {
+	if tc2.GetResult() != "3628800" {
 		t.Error("Did not get correct results got: " + tc2.GetResult())
 	}
 	ok, val := tc2.GetVarValue("result")
@@ -317,3 +392,691 @@ func TestFileWrite(t *testing.T) {
 		t.Error("Did not get correct results got: " + val)
 	}
 }
+
+func TestFileOutput(t *testing.T) {
+	var out, errOut bytes.Buffer
+	tc := tcl.NewTCL()
+	Init(tc)
+	tc.SetOutput(&out)
+	tc.SetErrorOutput(&errOut)
+
+	ret := tc.EvalString("puts \"hello\"; puts stderr oops; puts -nonewline stdout world")
+	if ret != nil {
+		t.Error("puts returned error: " + tc.GetResult())
+	}
+	if out.String() != "hello\nworld" {
+		t.Errorf("stdout got: '%s'", out.String())
+	}
+	if errOut.String() != "oops\n" {
+		t.Errorf("stderr got: '%s'", errOut.String())
+	}
+
+	testCases := []cases{
+		{"puts hello", "hello\n", tcl.RetOk},
+		{"puts stdout hello", "hello\n", tcl.RetOk},
+		{"puts -nonewline hello", "hello", tcl.RetOk},
+		{"puts -nonewline stdout hello", "hello", tcl.RetOk},
+		{"puts -channel stdout hello", "hello\n", tcl.RetOk},
+		{"puts -nonewline", "-nonewline\n", tcl.RetOk},
+		{"puts stdout", "stdout\n", tcl.RetOk},
+		{"puts bogus hello", "", tcl.RetError},
+		{"puts -nonewline bogus hello", "", tcl.RetError},
+		{"puts stdout hello extra", "", tcl.RetError},
+	}
+
+	for _, test := range testCases {
+		out.Reset()
+		ret := tc.EvalString(test.test)
+		switch test.res {
+		case tcl.RetOk:
+			if ret != nil {
+				t.Errorf("Eval %s returned error: '%s'", test.test, tc.GetResult())
+			}
+		case tcl.RetError:
+			if ret == nil {
+				t.Error("Eval did not return error as expected", test.test)
+			}
+		}
+		if test.match != out.String() {
+			t.Errorf("Eval %s wrote wrong output, got: '%s' expected: '%s'", test.test, out.String(), test.match)
+		}
+	}
+}
+
+// Create a connected pair of TCP connections on loopback.
+func socketPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listen.Close()
+
+	client, err := net.Dial("tcp", listen.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := listen.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func TestSocketClient(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listen.Close()
+	_, port, _ := net.SplitHostPort(listen.Addr().String())
+
+	// Echo one line back to client.
+	go func() {
+		conn, err := listen.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		_, _ = conn.Write([]byte("echo " + line))
+	}()
+
+	tc := tcl.NewTCL()
+	Init(tc)
+	if tc.EvalString("set ch [socket -myaddr 127.0.0.1 127.0.0.1 "+port+"]") != nil {
+		t.Fatal("unable to connect: " + tc.GetResult())
+	}
+	if !strings.HasPrefix(tc.GetResult(), "sock") {
+		t.Errorf("channel name not socket: '%s'", tc.GetResult())
+	}
+	if tc.EvalString("puts $ch hello; flush $ch; gets $ch") != nil {
+		t.Fatal("unable to talk to server: " + tc.GetResult())
+	}
+	if tc.GetResult() != "echo hello" {
+		t.Errorf("wrong reply got: '%s'", tc.GetResult())
+	}
+	if tc.EvalString("close $ch") != nil {
+		t.Error("unable to close socket: " + tc.GetResult())
+	}
+
+	if tc.EvalString("socket 127.0.0.1 "+port) != nil {
+		t.Error("unable to connect second time: " + tc.GetResult())
+	}
+	listen.Close()
+	if tc.EvalString("socket 127.0.0.1 "+port) == nil {
+		t.Error("connect to closed port did not fail")
+	}
+	if tc.EvalString("socket 127.0.0.1") == nil {
+		t.Error("socket without port did not fail")
+	}
+	if tc.EvalString("socket -server accept -myport 1 0") == nil {
+		t.Error("server socket with -myport did not fail")
+	}
+}
+
+func TestHalfClose(t *testing.T) {
+	client, server := socketPair(t)
+	defer client.Close()
+
+	tc := tcl.NewTCL()
+	Init(tc)
+	files, ok := tc.Data["file"].(*tclFileData)
+	if !ok {
+		t.Fatal("file data not found")
+	}
+	files.channels["sock1"] = newSocketChannel(server)
+	files.eof["sock1"] = false
+
+	// Send final response and close write side.
+	if tc.EvalString("puts -nonewline sock1 done; close sock1 -write") != nil {
+		t.Fatal("close -write failed: " + tc.GetResult())
+	}
+	data, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "done" {
+		t.Errorf("client read got: '%s'", string(data))
+	}
+
+	if tc.EvalString("puts sock1 more") == nil {
+		t.Error("puts after close -write did not fail")
+	}
+
+	// Read side still open.
+	if _, err := client.Write([]byte("request\n")); err != nil {
+		t.Fatal(err)
+	}
+	if tc.EvalString("gets sock1") != nil || tc.GetResult() != "request" {
+		t.Errorf("gets after close -write got: '%s'", tc.GetResult())
+	}
+
+	if tc.EvalString("close sock1 -read; file channels sock*") != nil {
+		t.Error("close -read failed: " + tc.GetResult())
+	}
+	if tc.GetResult() != "" {
+		t.Errorf("channel not removed after both sides closed: '%s'", tc.GetResult())
+	}
+
+	if tc.EvalString("set fd [open "+os.DevNull+"]; close $fd -read") == nil {
+		t.Error("half close of file did not fail")
+	}
+	if tc.EvalString("close stdout bogus") == nil {
+		t.Error("close with invalid direction did not fail")
+	}
+}
+
+func TestSocketServer(t *testing.T) {
+	tc := tcl.NewTCL()
+	Init(tc)
+	ret := tc.EvalString("set chans {}; proc accept {ch addr port} { global chans; gets $ch line; lappend chans $ch $line }")
+	if ret != nil {
+		t.Fatal("unable to create accept proc: " + tc.GetResult())
+	}
+	if tc.EvalString("set server [socket -server accept -myaddr 127.0.0.1 0]") != nil {
+		t.Fatal("unable to create server: " + tc.GetResult())
+	}
+	server := tc.GetResult()
+	files, ok := tc.Data["file"].(*tclFileData)
+	if !ok {
+		t.Fatal("file data not found")
+	}
+	addr := files.channels[server].listener.Addr().String()
+
+	for _, msg := range []string{"first", "second"} {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte(msg + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		if tc.EvalString("vwait chans") != nil {
+			t.Fatal("vwait failed: " + tc.GetResult())
+		}
+	}
+
+	if tc.EvalString("set chans") != nil {
+		t.Fatal("chans not set")
+	}
+	list := tc.ParseArgs(tc.GetResult())
+	if len(list) != 4 || list[1] != "first" || list[3] != "second" {
+		t.Fatalf("accept not called correctly: '%s'", tc.GetResult())
+	}
+	if list[0] == list[2] || list[0] == server || list[2] == server {
+		t.Errorf("channels not unique: '%s' server %s", tc.GetResult(), server)
+	}
+
+	if tc.EvalString("close $server; close "+list[0]+"; close "+list[2]) != nil {
+		t.Error("unable to close sockets: " + tc.GetResult())
+	}
+	if tc.EvalString("socket -server accept") == nil {
+		t.Error("socket without port did not fail")
+	}
+}
+
+func TestReadDelim(t *testing.T) {
+	// Create a test file.
+	tmp, err := os.MkdirTemp("/tmp", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "delim.txt")
+	err = os.WriteFile(path, []byte("first line\n  {a {b \\} c}} \"x y\" word\nab\x00cd"), 0o644)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	testCases := []cases{
+		{"set fd [open " + path + "] ; read -delim \\n $fd", "first line\n", tcl.RetOk},
+		{"set fd [open " + path + "] ; read -delim \\n $fd; read -delim \\n $fd", "  {a {b \\} c}} \"x y\" word\n", tcl.RetOk},
+		{"set fd [open " + path + "] ; gets $fd; read -string $fd", "{a {b \\} c}}", tcl.RetOk},
+		{"set fd [open " + path + "] ; gets $fd; read -string $fd; read -string $fd", "\"x y\"", tcl.RetOk},
+		{"set fd [open " + path + "] ; gets $fd; read -string $fd; read -string $fd; read -string $fd", "word", tcl.RetOk},
+		{"set fd [open " + path + "] ; gets $fd; gets $fd; read -delim \\0 $fd", "ab\x00", tcl.RetOk},
+		{"set fd [open " + path + "] ; gets $fd; gets $fd; read -delim \\0 $fd; eof $fd", "0", tcl.RetOk},
+		{"set fd [open " + path + "] ; gets $fd; gets $fd; read -delim x $fd", "ab\x00cd", tcl.RetOk},
+		{"set fd [open " + path + "] ; gets $fd; gets $fd; read -delim x $fd; eof $fd", "1", tcl.RetOk},
+		{"set fd [open " + path + "] ; read -delim ab $fd", "", tcl.RetError},
+		{"read -delim", "", tcl.RetError},
+	}
+
+	evalCases(t, testCases, "")
+}
+
+func TestNonBlocking(t *testing.T) {
+	client, server := socketPair(t)
+	defer client.Close()
+
+	tc := tcl.NewTCL()
+	Init(tc)
+	files, ok := tc.Data["file"].(*tclFileData)
+	if !ok {
+		t.Fatal("file data not found")
+	}
+	files.channels["sock1"] = newSocketChannel(server)
+	files.eof["sock1"] = false
+
+	if tc.EvalString("fconfigure sock1 -blocking 0; fconfigure sock1 -blocking") != nil || tc.GetResult() != "0" {
+		t.Fatalf("fconfigure -blocking got: '%s'", tc.GetResult())
+	}
+
+	// No data, must not wait.
+	if tc.EvalString("chan gets sock1 line") != nil || tc.GetResult() != "-1" {
+		t.Errorf("chan gets with no data got: '%s'", tc.GetResult())
+	}
+	if tc.EvalString("chan read sock1 100") != nil || tc.GetResult() != "" {
+		t.Errorf("chan read with no data got: '%s'", tc.GetResult())
+	}
+
+	if _, err := client.Write([]byte("hello\npartial")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for input to be collected.
+	for range 100 {
+		if tc.EvalString("chan gets sock1 line") != nil || tc.GetResult() != "-1" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if tc.GetResult() != "5" || tc.EvalString("set line") != nil || tc.GetResult() != "hello" {
+		t.Errorf("chan gets got: '%s'", tc.GetResult())
+	}
+
+	// Partial line is not returned by gets, but is by read.
+	if tc.EvalString("chan gets sock1 line") != nil || tc.GetResult() != "-1" {
+		t.Errorf("chan gets partial line got: '%s'", tc.GetResult())
+	}
+	if tc.EvalString("chan read -nonewline sock1 3") != nil || tc.GetResult() != "par" {
+		t.Errorf("chan read got: '%s'", tc.GetResult())
+	}
+
+	// Back to blocking, read waits for end of input.
+	client.Close()
+	if tc.EvalString("fconfigure sock1 -blocking 1; read sock1") != nil || tc.GetResult() != "tial" {
+		t.Errorf("blocking read got: '%s'", tc.GetResult())
+	}
+
+	if tc.EvalString("fconfigure sock1 -bogus 1") == nil {
+		t.Error("fconfigure with bad option did not fail")
+	}
+	if tc.EvalString("chan bogus sock1") == nil {
+		t.Error("chan with bad subcommand did not fail")
+	}
+}
+
+func TestBuffering(t *testing.T) {
+	tmp, err := os.MkdirTemp("/tmp", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "buffer.txt")
+
+	tc := tcl.NewTCL()
+	Init(tc)
+
+	fileSize := func() int64 {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+
+	if tc.EvalString("set fd [open "+path+" w]; fconfigure $fd -buffering full; fconfigure $fd -buffering") != nil ||
+		tc.GetResult() != "full" {
+		t.Fatalf("fconfigure -buffering got: '%s'", tc.GetResult())
+	}
+	if tc.EvalString("puts $fd one; puts $fd two; puts $fd three") != nil {
+		t.Fatal(tc.GetResult())
+	}
+	if size := fileSize(); size != 0 {
+		t.Errorf("full buffering wrote %d bytes before flush", size)
+	}
+	if tc.EvalString("flush $fd") != nil {
+		t.Fatal(tc.GetResult())
+	}
+	if size := fileSize(); size != 14 {
+		t.Errorf("flush wrote %d bytes expected 14", size)
+	}
+
+	if tc.EvalString("fconfigure $fd -buffering line; puts -nonewline $fd four") != nil {
+		t.Fatal(tc.GetResult())
+	}
+	if size := fileSize(); size != 14 {
+		t.Errorf("line buffering wrote partial line, size %d", size)
+	}
+	if tc.EvalString("puts $fd \" five\"") != nil {
+		t.Fatal(tc.GetResult())
+	}
+	if size := fileSize(); size != 24 {
+		t.Errorf("line buffering wrote %d bytes expected 24", size)
+	}
+
+	if tc.EvalString("fconfigure $fd -buffering full; puts $fd six; close $fd") != nil {
+		t.Fatal(tc.GetResult())
+	}
+	if size := fileSize(); size != 28 {
+		t.Errorf("close wrote %d bytes expected 28", size)
+	}
+
+	if tc.EvalString("fconfigure stdout -buffering bogus") == nil {
+		t.Error("fconfigure with bad buffering did not fail")
+	}
+}
+
+func TestFConfigure(t *testing.T) {
+	tmp, err := os.MkdirTemp("/tmp", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "config.txt")
+
+	testCases := []cases{
+		{"set fd [open " + path + " w]; fconfigure $fd", "-blocking 1 -buffering none -buffersize 4096 -encoding utf-8 -translation auto", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -blocking", "1", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -buffering line; fconfigure $fd -buffering", "line", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -buffering full -buffersize 100; fconfigure $fd",
+			"-blocking 1 -buffering full -buffersize 100 -encoding utf-8 -translation auto", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -encoding utf-8 -translation auto", "", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -buffersize 0", "", tcl.RetError},
+		{"set fd [open " + path + " w]; fconfigure $fd -encoding ascii", "", tcl.RetError},
+		{"set fd [open " + path + " w]; fconfigure $fd -bogus", "", tcl.RetError},
+		{"set fd [open " + path + " w]; fconfigure $fd -buffering", "none", tcl.RetOk},
+		{"fconfigure nochannel", "", tcl.RetError},
+		{"set fd [open " + path + " w]; fconfigure $fd -translation crlf; fconfigure $fd -translation", "crlf", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -translation binary; fconfigure $fd", "-blocking 1 -buffering none -buffersize 4096 -encoding binary -translation lf", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -translation bogus", "", tcl.RetError},
+		{"set fd [open " + path + " w]; fconfigure $fd -translation crlf; puts $fd a; puts $fd b; close $fd; " +
+			"set fd [open " + path + "]; fconfigure $fd -translation binary; string length [read $fd]", "6", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -translation crlf; puts $fd a; puts $fd b; close $fd; " +
+			"set fd [open " + path + "]; read $fd", "a\nb\n", tcl.RetOk},
+		{"set fd [open " + path + " w]; puts -nonewline $fd a\\nb; close $fd; " +
+			"set fd [open " + path + "]; list [gets $fd line] [gets $fd line] $line [gets $fd line]", "1 1 b -1", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -translation cr; puts $fd one; puts $fd two; close $fd; " +
+			"set fd [open " + path + "]; list [gets $fd] [gets $fd]", "one two", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -translation crlf; puts $fd one; puts $fd two; close $fd; " +
+			"set fd [open " + path + "]; fconfigure $fd -translation lf; string length [gets $fd]", "4", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -translation binary; puts -nonewline $fd [binary format c* {0 127 128 200 255}]; close $fd; " +
+			"set fd [open " + path + "]; fconfigure $fd -translation binary; binary scan [read $fd] cu* b; set b", "0 127 128 200 255", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -encoding binary; puts -nonewline $fd [binary format c 200]; close $fd; file size " + path, "1", tcl.RetOk},
+		{"set l {}; for {set i 128} {$i < 256} {incr i} {lappend l $i}; set fd [open " + path + " w]; fconfigure $fd -translation binary; " +
+			"puts -nonewline $fd [binary format c* $l]; close $fd; set fd [open " + path + "]; fconfigure $fd -translation binary; " +
+			"binary scan [read $fd] cu* b; string equal $b $l", "1", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -encoding iso8859-1; puts -nonewline $fd \u00e9; close $fd; " +
+			"set fd [open " + path + "]; fconfigure $fd -translation binary; string length [read $fd]", "1", tcl.RetOk},
+		{"set fd [open " + path + " w]; fconfigure $fd -encoding iso8859-1; puts -nonewline $fd \u00e9; close $fd; " +
+			"set fd [open " + path + "]; fconfigure $fd -encoding iso8859-1; read $fd", "\u00e9", tcl.RetOk},
+	}
+
+	evalCases(t, testCases, "close $fd")
+}
+
+func TestGlob(t *testing.T) {
+	tmp := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "run.sh"} {
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(tmp, "run.sh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tmp, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(tmp, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmp, "sub", "deep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sub/y.go", "sub/deep/x.go", "sub/-dash"} {
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []cases{
+		{"glob -directory " + tmp + " -tails *.txt", "a.txt b.txt", tcl.RetOk},
+		{"glob -tails *.txt", "", tcl.RetError},
+		{"glob -path " + tmp + "/r *", filepath.Join(tmp, "run.sh"), tcl.RetOk},
+		{"glob -path " + tmp + "/r -tails *", "run.sh", tcl.RetOk},
+		{"glob -path " + tmp + "/r -directory " + tmp + " *", "", tcl.RetError},
+		{"glob -directory " + tmp + " **/*.go", filepath.Join(tmp, "sub", "deep", "x.go") + " " + filepath.Join(tmp, "sub", "y.go"), tcl.RetOk},
+		{"glob -directory " + tmp + " -tails **/*.go", filepath.Join("sub", "deep", "x.go") + " " + filepath.Join("sub", "y.go"), tcl.RetOk},
+		{"glob " + tmp + "/**/deep", filepath.Join(tmp, "sub", "deep"), tcl.RetOk},
+		{"glob -directory " + tmp + "/sub -tails -- -dash", "-dash", tcl.RetOk},
+		{"glob -directory " + tmp + " *.txt", filepath.Join(tmp, "a.txt") + " " + filepath.Join(tmp, "b.txt"), tcl.RetOk},
+		{"glob " + tmp + "/s*", filepath.Join(tmp, "sub"), tcl.RetOk},
+		{"glob -directory " + tmp + " -types f *", filepath.Join(tmp, "a.txt") + " " + filepath.Join(tmp, "b.txt") + " " +
+			filepath.Join(tmp, "link") + " " + filepath.Join(tmp, "run.sh"), tcl.RetOk},
+		{"glob -directory " + tmp + " -types d *", filepath.Join(tmp, "sub"), tcl.RetOk},
+		{"glob -directory " + tmp + " -types l *", filepath.Join(tmp, "link"), tcl.RetOk},
+		{"glob -directory " + tmp + " -types {d r} *", filepath.Join(tmp, "sub"), tcl.RetOk},
+		{"glob -directory " + tmp + " -types {f x} *", filepath.Join(tmp, "run.sh"), tcl.RetOk},
+		{"glob -directory " + tmp + " -types {f d} s*", filepath.Join(tmp, "sub"), tcl.RetOk},
+		{"glob -directory " + tmp + " *.none", "", tcl.RetError},
+		{"glob -nocomplain -directory " + tmp + " *.none", "", tcl.RetOk},
+		{"glob -nocomplain -directory " + tmp + " -types d *.txt", "", tcl.RetOk},
+		{"glob -types q *", "", tcl.RetError},
+		{"glob", "", tcl.RetError},
+	}
+
+	evalCases(t, testCases, "")
+}
+
+func TestGlobAccess(t *testing.T) {
+	tmp := t.TempDir()
+	// Readable by others only, and by nobody.
+	for name, perm := range map[string]os.FileMode{"others": 0o004, "locked": 0o000} {
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte("x"), perm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(filepath.Join(tmp, name), perm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only root may read files without owner read permission.
+	expect := ""
+	if os.Geteuid() == 0 {
+		expect = "locked others"
+	}
+	tc := tcl.NewTCL()
+	Init(tc)
+	if tc.EvalString("lsort [glob -nocomplain -tails -directory "+tmp+" -types r *]") != nil || tc.GetResult() != expect {
+		t.Errorf("glob -types r got: '%s' expected: '%s'", tc.GetResult(), expect)
+	}
+}
+
+func TestSource(t *testing.T) {
+	tmp := t.TempDir()
+	lib := t.TempDir()
+	files := map[string]string{
+		filepath.Join(tmp, "ok.tcl"):     "set a 1\nset b 2",
+		filepath.Join(tmp, "ret.tcl"):    "set a 1\nreturn done\nset a 2",
+		filepath.Join(tmp, "bad.tcl"):    "set a 1\nerror {bad file}",
+		filepath.Join(tmp, "syntax.tcl"): "set a {1",
+		filepath.Join(lib, "lib.tcl"):    "proc libproc {} {return lib}",
+	}
+	for name, text := range files {
+		if err := os.WriteFile(name, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []cases{
+		{"source " + filepath.Join(tmp, "ok.tcl"), "2", tcl.RetOk},
+		{"source " + filepath.Join(tmp, "ret.tcl"), "done", tcl.RetOk},
+		{"source " + filepath.Join(tmp, "ret.tcl") + "; set a", "1", tcl.RetOk},
+		{"proc f {} {source " + filepath.Join(tmp, "ret.tcl") + "; return after}; f", "after", tcl.RetOk},
+		{"source " + filepath.Join(tmp, "bad.tcl"), "bad file", tcl.RetError},
+		{"catch {source " + filepath.Join(tmp, "bad.tcl") + "} msg; set msg", "bad file", tcl.RetOk},
+		{"source " + filepath.Join(tmp, "syntax.tcl"), "error parsing: set a {1", tcl.RetError},
+		{"source " + filepath.Join(tmp, "none.tcl"), "couldn't read file \"" + filepath.Join(tmp, "none.tcl") +
+			"\": open " + filepath.Join(tmp, "none.tcl") + ": no such file or directory", tcl.RetError},
+		{"set auto_path {" + tmp + " " + lib + "}; source lib.tcl; libproc", "lib", tcl.RetOk},
+		{"source lib.tcl", "couldn't read file \"lib.tcl\": open lib.tcl: no such file or directory", tcl.RetError},
+		{"source", "source file ?args", tcl.RetError},
+	}
+
+	for _, test := range testCases {
+		tc := tcl.NewTCL()
+		Init(tc)
+		ret := tc.Eval(test.test)
+		if ret != test.res {
+			t.Errorf("Eval %s returned code %d expected %d", test.test, ret, test.res)
+		}
+		if test.match != tc.GetResult() {
+			t.Errorf("Eval %s returned wrong result, got: '%s' expected: '%s'", test.test, tc.GetResult(), test.match)
+		}
+	}
+}
+
+func TestChanEvent(t *testing.T) {
+	client, server := socketPair(t)
+	defer client.Close()
+
+	tc := tcl.NewTCL()
+	Init(tc)
+	files, ok := tc.Data["file"].(*tclFileData)
+	if !ok {
+		t.Fatal("file data not found")
+	}
+	files.channels["sock1"] = newSocketChannel(server)
+	files.eof["sock1"] = false
+
+	script := "chan event sock1 readable {gets sock1 line; set got $line}; chan event sock1 readable"
+	if tc.EvalString(script) != nil || tc.GetResult() != "gets sock1 line; set got $line" {
+		t.Fatalf("chan event readable got: '%s'", tc.GetResult())
+	}
+
+	if _, err := client.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	if tc.EvalString("after 2000 {set got timeout}; vwait got; set got") != nil || tc.GetResult() != "hello" {
+		t.Errorf("readable handler got: '%s'", tc.GetResult())
+	}
+
+	// Removed handler is not run.
+	if tc.EvalString("chan event sock1 readable {}; set got none") != nil {
+		t.Fatal(tc.GetResult())
+	}
+	if _, err := client.Write([]byte("again\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if tc.EvalString("update; set got") != nil || tc.GetResult() != "none" {
+		t.Errorf("removed handler was run, got: '%s'", tc.GetResult())
+	}
+
+	script = "chan event sock1 writable {puts sock1 reply; chan event sock1 writable {}; set done 1}; vwait done"
+	if tc.EvalString(script) != nil {
+		t.Fatal(tc.GetResult())
+	}
+	reply := make([]byte, 6)
+	if _, err := io.ReadFull(client, reply); err != nil || string(reply) != "reply\n" {
+		t.Errorf("writable handler wrote: '%s'", reply)
+	}
+
+	if tc.EvalString("chan event sock1 bogus {}") == nil {
+		t.Error("chan event with bad event did not fail")
+	}
+}
+
+func TestPipe(t *testing.T) {
+	testCases := []cases{
+		{"set fd [open {|echo hello world}]; gets $fd", "hello world", tcl.RetOk},
+		{"set fd [open {|echo hello}]; list [gets $fd line] $line [gets $fd line] [eof $fd]", "5 hello -1 1", tcl.RetOk},
+		{"set fd [open |cat r+]; puts $fd abc; gets $fd", "abc", tcl.RetOk},
+		{"set fd [open {|tr a-z A-Z} r+]; puts $fd abc; close $fd -write; read $fd", "ABC\n", tcl.RetOk},
+		{"set fd [open {|sh -c {exit 3}}]; list [catch {close $fd} msg] $msg [lindex $errorCode 2]",
+			"1 {child process exited abnormally} 3", tcl.RetOk},
+		{"set fd [open |true]; close $fd; file channels pipe*", "", tcl.RetOk},
+		{"set fd [open |true]; string match pipe* $fd", "1", tcl.RetOk},
+		{"set fd [open |no_such_command_here]", "", tcl.RetError},
+		{"set fd [open |]", "", tcl.RetError},
+	}
+
+	evalCases(t, testCases, "catch {close $fd}")
+}
+
+func TestFileEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := tcl.NewTCL()
+	Init(tc)
+	script := "set fd [open " + path + "]; set lines {}\n" +
+		"fileevent $fd readable {if {[gets $fd line] < 0} {set done 1} else {lappend lines $line}}\n" +
+		"after 2000 {set done timeout}; vwait done; fileevent $fd readable {}; close $fd; set lines"
+	if tc.EvalString(script) != nil || tc.GetResult() != "one two" {
+		t.Errorf("fileevent readable got: '%s'", tc.GetResult())
+	}
+
+	if tc.EvalString("set fd [open "+path+"]; fileevent $fd bogus {}") == nil {
+		t.Error("fileevent with bad event did not fail")
+	}
+}
+
+func TestTransferChannel(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "transfer.txt")
+	if err := os.WriteFile(name, []byte("line one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	parent := tcl.NewTCL()
+	Init(parent)
+	child := tcl.NewTCL()
+	Init(child)
+
+	if parent.EvalString("open "+name) != nil {
+		t.Fatal(parent.GetResult())
+	}
+	channel := parent.GetResult()
+	if err := TransferChannel(parent, child, channel); err != nil {
+		t.Fatal(err)
+	}
+
+	if child.EvalString("gets "+channel) != nil || child.GetResult() != "line one" {
+		t.Errorf("child read got: '%s'", child.GetResult())
+	}
+	if parent.EvalString("gets "+channel) == nil {
+		t.Errorf("parent can still read transferred channel: '%s'", parent.GetResult())
+	}
+	if err := TransferChannel(parent, child, channel); err == nil {
+		t.Error("transfer of missing channel did not fail")
+	}
+	if err := TransferChannel(parent, child, "stdout"); err == nil {
+		t.Error("transfer of standard channel did not fail")
+	}
+	if child.EvalString("close "+channel) != nil {
+		t.Error(child.GetResult())
+	}
+
+	// Socket names made by child skip names of transferred sockets.
+	parentFiles, _ := parent.Data["file"].(*tclFileData)
+	server, client := net.Pipe()
+	defer server.Close()
+	parentFiles.channels[parentFiles.newSocketName()] = newSocketChannel(client)
+	if err := TransferChannel(parent, child, "sock0"); err != nil {
+		t.Fatal(err)
+	}
+	childFiles, _ := child.Data["file"].(*tclFileData)
+	if name := childFiles.newSocketName(); name != "sock1" {
+		t.Errorf("child socket name got: '%s'", name)
+	}
+	if child.EvalString("close sock0") != nil {
+		t.Error(child.GetResult())
+	}
+}
@@ -26,41 +26,246 @@
 package tclfile
 
 import (
+	"bufio"
+	"errors"
 	"io"
+	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 
 	tcl "github.com/rcornwell/tinyTCL/tcl"
 )
 
 type tclFileData struct {
-	channels map[string]*os.File // Pointer to open file names.
-	eof      map[string]bool     // Has file hit EOF.
+	channels map[string]*tclChannel // Pointer to open channels.
+	eof      map[string]bool        // Has file hit EOF.
+	sockets  int                    // Number for next socket channel.
+}
+
+// Standard channels follow the output of the interpreter.
+const (
+	stdNone = iota
+	stdOutput
+	stdError
+)
+
+// Channels which are always open.
+var stdChannels = []string{"stdin", "stdout", "stderr"}
+
+// Return true if name is one of the standard channels.
+func isStdChannel(name string) bool {
+	for _, std := range stdChannels {
+		if name == std {
+			return true
+		}
+	}
+	return false
+}
+
+// Open channel.
+type tclChannel struct {
+	file     *os.File     // Open file, nil if not backed by a file.
+	conn     net.Conn     // Network connection, nil if not a socket.
+	listener net.Listener // Server socket, nil if not listening.
+	cmd      *exec.Cmd    // Command at other end of pipe, nil if not a pipe.
+	reader   io.Reader    // Input side of channel, nil if not readable.
+	writer   io.Writer    // Output side of channel, nil if not writable.
+	std      int          // Standard output channel.
+
+	cmdOutput io.Closer // Output of command, nil if not reading from pipe.
+
+	async  *asyncReader     // Background reader, nil until channel is non-blocking.
+	buffer *bufio.Writer    // Output buffer, nil until channel is buffered.
+	config tclChannelConfig // Options set by fconfigure.
+
+	readScript  string // Script run when channel is readable.
+	writeScript string // Script run when channel is writable.
+	writeWatch  bool   // Writable event is pending.
+}
+
+// Configurable options of channel, zero value is the default.
+type tclChannelConfig struct {
+	nonBlocking bool // Reads return only available input.
+	buffering   int  // How output is buffered.
+	bufferSize  int  // Size of output buffer, 0 for default size.
+	translation int  // How end of line is translated.
+	encoding    int  // How characters are converted to bytes.
+	skipLF      bool // Input ended with carriage return, skip next newline.
+}
+
+// Output buffering modes.
+const (
+	bufferNone = iota // Write output immediately.
+	bufferLine        // Write output at end of each line.
+	bufferFull        // Write output when buffer full or flushed.
+)
+
+var bufferModes = []string{"none", "line", "full"}
+
+// End of line translation modes.
+const (
+	translateAuto = iota // Input ends with lf, crlf or cr, output with lf.
+	translateLF          // Lines end with lf.
+	translateCRLF        // Lines end with cr lf.
+	translateCR          // Lines end with cr.
+)
+
+var translationModes = []string{"auto", "lf", "crlf", "cr"}
+
+// Character encodings.
+const (
+	encodingUTF8   = iota // Characters are stored as utf-8.
+	encodingLatin1        // Characters are stored as single bytes.
+	encodingBinary        // Bytes are passed unchanged.
+)
+
+var encodingNames = []string{"utf-8", "iso8859-1", "binary"}
+
+// Connections that can close each direction separately.
+type halfCloser interface {
+	CloseRead() error
+	CloseWrite() error
+}
+
+// Create a channel for an open file.
+func newFileChannel(file *os.File) *tclChannel {
+	return &tclChannel{file: file, reader: file, writer: file}
+}
+
+// Create a channel for a network connection.
+func newSocketChannel(conn net.Conn) *tclChannel {
+	return &tclChannel{conn: conn, reader: conn, writer: conn}
+}
+
+// Close channel, or one direction of channel.
+func (ch *tclChannel) close(read bool, write bool) error {
+	if write {
+		if err := ch.flush(); err != nil {
+			return err
+		}
+	}
+	if ch.cmd != nil {
+		return ch.closePipe(read, write)
+	}
+	if read && write {
+		switch {
+		case ch.file != nil:
+			return ch.file.Close()
+		case ch.conn != nil:
+			return ch.conn.Close()
+		case ch.listener != nil:
+			return ch.listener.Close()
+		}
+		return nil
+	}
+
+	half, ok := ch.conn.(halfCloser)
+	if !ok {
+		return errors.New("half-close of channel not supported")
+	}
+	var err error
+	if read {
+		ch.reader = nil
+		err = half.CloseRead()
+	} else {
+		ch.writer = nil
+		err = half.CloseWrite()
+	}
+
+	// Release connection once both directions are closed.
+	if ch.reader == nil && ch.writer == nil {
+		return ch.conn.Close()
+	}
+	return err
+}
+
+// Return where output to channel should go.
+func (ch *tclChannel) output(t *tcl.Tcl) io.Writer {
+	switch ch.std {
+	case stdOutput:
+		return t.Output()
+	case stdError:
+		return t.ErrorOutput()
+	}
+	return ch.writer
 }
 
 // Register commands.
 func Init(t *tcl.Tcl) {
+	t.Register("chan", cmdChan)
 	t.Register("close", cmdClose)
 	t.Register("eof", cmdEOF)
+	t.Register("fconfigure", cmdFConfigure)
 	t.Register("file", cmdFile)
+	t.Register("fileevent", chanEvent)
 	t.Register("flush", cmdFlush)
 	t.Register("gets", cmdGets)
+	t.Register("glob", cmdGlob)
 	t.Register("open", cmdOpen)
 	t.Register("read", cmdRead)
 	t.Register("puts", cmdPuts)
+	t.Register("socket", cmdSocket)
 	t.Register("seek", func(t *tcl.Tcl, a []string) int { return cmdSeek(t, a, "seek") })
 	t.Register("source", cmdSource)
 	t.Register("tell", func(t *tcl.Tcl, a []string) int { return cmdSeek(t, a, "tell") })
 	data := tclFileData{}
-	data.channels = make(map[string]*os.File)
+	data.channels = make(map[string]*tclChannel)
 	data.eof = make(map[string]bool)
-	data.channels["stdin"] = os.Stdin
+	data.channels["stdin"] = &tclChannel{file: os.Stdin, reader: os.Stdin}
 	data.eof["stdin"] = false
-	data.channels["stdout"] = os.Stdout
+	data.channels["stdout"] = &tclChannel{std: stdOutput}
 	data.eof["stdout"] = false
-	data.channels["stderr"] = os.Stderr
+	data.channels["stderr"] = &tclChannel{std: stdError}
 	data.eof["stderr"] = false
 	t.Data["file"] = &data
+	t.AddEventSource(data.hasHandlers)
+}
+
+// Return true if any channel has an event script or is accepting connections.
+func (files *tclFileData) hasHandlers() bool {
+	for _, ch := range files.channels {
+		if ch.readScript != "" || ch.writeScript != "" || ch.listener != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Move an open channel from one interpreter to another, both must have the
+// file extension. Event scripts belong to the source and are removed.
+func TransferChannel(from *tcl.Tcl, to *tcl.Tcl, channel string) error {
+	src, ok := from.Data["file"].(*tclFileData)
+	if !ok {
+		panic("invalid data type file extension")
+	}
+	dst, ok := to.Data["file"].(*tclFileData)
+	if !ok {
+		panic("invalid data type file extension")
+	}
+
+	ch, ok := src.channels[channel]
+	if !ok {
+		return errors.New("channel " + channel + " not found")
+	}
+	if isStdChannel(channel) {
+		return errors.New("can't transfer standard channel " + channel)
+	}
+	if _, ok := dst.channels[channel]; ok {
+		return errors.New("channel " + channel + " already exists")
+	}
+
+	ch.readScript = ""
+	ch.writeScript = ""
+	if ch.async != nil {
+		ch.async.watch(nil)
+	}
+	dst.channels[channel] = ch
+	dst.eof[channel] = src.eof[channel]
+	delete(src.channels, channel)
+	delete(src.eof, channel)
+	return nil
 }
 
 // Open a file, return channel identifier.
@@ -89,23 +294,28 @@ func cmdOpen(t *tcl.Tcl, args []string) int {
 		return t.SetResult(tcl.RetError, "invalid access mode "+access)
 	}
 
-	perm, _, pok := tcl.ConvertStringToNumber(perms, 10, 0)
-	if !pok {
-		return t.SetResult(tcl.RetError, "invalid permissions "+perms)
-	}
-
 	files, ok := t.Data["file"].(*tclFileData)
 	if !ok {
 		panic("invalid data type file extension")
 	}
 
+	// Names starting with | run a command.
+	if strings.HasPrefix(name, "|") {
+		return openPipe(t, files, name[1:], mode)
+	}
+
+	perm, _, pok := tcl.ConvertStringToNumber(perms, 10, 0)
+	if !pok {
+		return t.SetResult(tcl.RetError, "invalid permissions "+perms)
+	}
+
 	file, err := os.OpenFile(name, mode, os.FileMode(perm))
 	if err != nil {
 		return t.SetResult(tcl.RetError, "unable to open file "+name+" "+err.Error())
 	}
 
 	channel := "file" + tcl.ConvertNumberToString(int(file.Fd()), 10)
-	files.channels[channel] = file
+	files.channels[channel] = newFileChannel(file)
 	files.eof[channel] = false
 	return t.SetResult(tcl.RetOk, channel)
 }
@@ -113,7 +323,7 @@ func cmdOpen(t *tcl.Tcl, args []string) int {
 // Close a file based on channel identifier.
 func cmdClose(t *tcl.Tcl, args []string) int {
 	if len(args) < 2 || len(args) > 3 {
-		return t.SetResult(tcl.RetError, "close channel")
+		return t.SetResult(tcl.RetError, "close channel ?direction")
 	}
 
 	files, ok := t.Data["file"].(*tclFileData)
@@ -121,19 +331,57 @@ func cmdClose(t *tcl.Tcl, args []string) int {
 		panic("invalid data type file extension")
 	}
 
-	file, ok := files.channels[args[1]]
+	if isStdChannel(args[1]) {
+		return t.SetResult(tcl.RetError, "can't close standard channel "+args[1])
+	}
+
+	ch, ok := files.channels[args[1]]
 	if !ok {
 		return t.SetResult(tcl.RetError, "file "+args[1]+" not opened")
 	}
 
-	err := file.Close()
-	if err != nil {
+	read := true
+	write := true
+	if len(args) == 3 {
+		switch args[2] {
+		case "-read", "read":
+			write = false
+		case "-write", "write":
+			read = false
+		default:
+			return t.SetResult(tcl.RetError, "invalid direction "+args[2])
+		}
+	}
+
+	// Stop events for closed directions.
+	if read {
+		ch.readScript = ""
+		if ch.async != nil {
+			ch.async.watch(nil)
+		}
+	}
+	if write {
+		ch.writeScript = ""
+	}
+
+	err := ch.close(read, write)
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
 		return t.SetResult(tcl.RetError, "unable to close file "+args[1]+" "+err.Error())
 	}
 
-	delete(files.channels, args[1])
-	delete(files.eof, args[1])
+	// Remove channel once both directions are closed.
+	if (read && write) || (ch.reader == nil && ch.writer == nil) {
+		delete(files.channels, args[1])
+		delete(files.eof, args[1])
+	}
 
+	// Exit status of command at end of pipe is put in errorCode.
+	if exitErr != nil {
+		t.SetErrorCode("CHILDSTATUS " + tcl.ConvertNumberToString(ch.cmd.Process.Pid, 10) + " " +
+			tcl.ConvertNumberToString(exitErr.ExitCode(), 10))
+		return t.SetResult(tcl.RetError, "child process exited abnormally")
+	}
 	return t.SetResult(tcl.RetOk, "")
 }
 
@@ -158,10 +406,11 @@ func cmdEOF(t *tcl.Tcl, args []string) int {
 	return t.SetResult(tcl.RetOk, "0")
 }
 
-// Return if channel is at EOF.
+// Read data from a channel.
 func cmdRead(t *tcl.Tcl, args []string) int {
+	usage := "read ?-nonewline ?-delim char ?-string channel numchars"
 	if len(args) < 2 {
-		return t.SetResult(tcl.RetError, "read ?-nonewline channel numchars")
+		return t.SetResult(tcl.RetError, usage)
 	}
 
 	files, ok := t.Data["file"].(*tclFileData)
@@ -170,29 +419,99 @@ func cmdRead(t *tcl.Tcl, args []string) int {
 	}
 
 	noNewline := false
+	delim := -1
+	token := false
 	i := 1
-	if args[i] == "-nonewline" {
-		noNewline = true
-		i++
+outer:
+	for ; i < len(args); i++ {
+		switch args[i] {
+		case "-nonewline":
+			noNewline = true
+		case "-delim":
+			i++
+			if i >= len(args) || len(args[i]) != 1 {
+				return t.SetResult(tcl.RetError, "-delim requires single character")
+			}
+			delim = int(args[i][0])
+		case "-string":
+			token = true
+		default:
+			break outer
+		}
 	}
 
-	if len(args) < i {
+	if len(args) <= i {
 		return t.SetResult(tcl.RetError, "no channel given")
 	}
 
-	file, ok := files.channels[args[i]]
+	ch, ok := files.channels[args[i]]
 	if !ok {
 		return t.SetResult(tcl.RetError, "file "+args[i]+" not opened")
 	}
 
-	bytes := 0
-	info, err := file.Stat()
-	if err != nil {
-		return t.SetResult(tcl.RetError, "read error "+err.Error())
+	if ch.reader == nil {
+		return t.SetResult(tcl.RetError, "channel "+args[i]+" not opened for reading")
+	}
+
+	if delim >= 0 || token {
+		var text string
+		var eof bool
+		var err error
+		if token {
+			text, eof, err = ch.readToken()
+		} else {
+			text, eof, err = ch.readDelim(byte(delim))
+		}
+		if err != nil {
+			return t.SetResult(tcl.RetError, "read error "+err.Error())
+		}
+		if eof {
+			files.eof[args[i]] = true
+		}
+		return t.SetResult(tcl.RetOk, text)
 	}
-	size := int(info.Size())
+
+	// Non-blocking channels return only input that is available.
+	if ch.config.nonBlocking {
+		size := -1
+		if len(args) > (i + 1) {
+			size, _, ok = tcl.ConvertStringToNumber(args[i+1], 10, 0)
+			if !ok {
+				return t.SetResult(tcl.RetError, "can't convert number of bytes to integer")
+			}
+		}
+		buffer, eof := ch.async.available(size)
+		if eof {
+			files.eof[args[i]] = true
+		}
+		text := ch.decode(buffer)
+		if noNewline {
+			text = strings.TrimSuffix(text, "\n")
+		}
+		return t.SetResult(tcl.RetOk, text)
+	}
+
+	bytes := 0
 	if len(args) <= (i + 1) {
-		pos, serr := file.Seek(0, 1)
+		// If not a file, read until end of input.
+		if ch.file == nil {
+			buffer, err := io.ReadAll(ch.reader)
+			if err != nil {
+				return t.SetResult(tcl.RetError, "read error "+err.Error())
+			}
+			files.eof[args[i]] = true
+			text := ch.decode(buffer)
+			if noNewline {
+				text = strings.TrimSuffix(text, "\n")
+			}
+			return t.SetResult(tcl.RetOk, text)
+		}
+		info, err := ch.file.Stat()
+		if err != nil {
+			return t.SetResult(tcl.RetError, "read error "+err.Error())
+		}
+		size := int(info.Size())
+		pos, serr := ch.file.Seek(0, 1)
 		if serr != nil {
 			return t.SetResult(tcl.RetError, "read error "+serr.Error())
 		}
@@ -205,7 +524,7 @@ func cmdRead(t *tcl.Tcl, args []string) int {
 	}
 
 	buffer := make([]byte, bytes)
-	n, rerr := file.Read(buffer)
+	n, rerr := ch.reader.Read(buffer)
 	if rerr != nil {
 		return t.SetResult(tcl.RetError, "read error "+rerr.Error())
 	}
@@ -214,10 +533,204 @@ func cmdRead(t *tcl.Tcl, args []string) int {
 		return t.SetResult(tcl.RetOk, "")
 	}
 
-	if noNewline && buffer[n-1] == '\n' {
-		n--
+	text := ch.decode(buffer[:n])
+	if noNewline {
+		text = strings.TrimSuffix(text, "\n")
+	}
+	return t.SetResult(tcl.RetOk, text)
+}
+
+// Write text to output of channel, buffering as configured.
+func (ch *tclChannel) write(out io.Writer, text string) error {
+	data := ch.encode(text)
+	if ch.config.buffering == bufferNone {
+		_, err := io.WriteString(out, data)
+		return err
+	}
+	if ch.buffer == nil {
+		ch.buffer = bufio.NewWriterSize(out, ch.config.size())
+	}
+	if _, err := ch.buffer.WriteString(data); err != nil {
+		return err
+	}
+	if ch.config.buffering == bufferLine && strings.Contains(text, "\n") {
+		return ch.buffer.Flush()
+	}
+	return nil
+}
+
+// Convert text to bytes written to channel, translating end of lines.
+func (ch *tclChannel) encode(text string) string {
+	switch ch.config.translation {
+	case translateCRLF:
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	case translateCR:
+		text = strings.ReplaceAll(text, "\n", "\r")
+	}
+	if ch.config.encoding != encodingLatin1 {
+		return text
+	}
+	data := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r > 0xff {
+			r = '?'
+		}
+		data = append(data, byte(r))
+	}
+	return string(data)
+}
+
+// Convert bytes read from channel to text, translating end of lines.
+func (ch *tclChannel) decode(data []byte) string {
+	text := ch.decodeChars(data)
+	switch ch.config.translation {
+	case translateAuto:
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		text = strings.ReplaceAll(text, "\r", "\n")
+	case translateCRLF:
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	case translateCR:
+		text = strings.ReplaceAll(text, "\r", "\n")
+	}
+	return text
+}
+
+// Convert bytes to characters according to channel encoding.
+func (ch *tclChannel) decodeChars(data []byte) string {
+	if ch.config.encoding != encodingLatin1 {
+		return string(data)
+	}
+	runes := make([]rune, len(data))
+	for i, by := range data {
+		runes[i] = rune(by)
+	}
+	return string(runes)
+}
+
+// Check if byte ends a line, returns line without end of line characters.
+func (ch *tclChannel) endOfLine(by byte, line []byte) ([]byte, bool) {
+	switch ch.config.translation {
+	case translateAuto:
+		switch by {
+		case '\n':
+			return line, true
+		case '\r':
+			ch.config.skipLF = true
+			return line, true
+		}
+	case translateLF:
+		if by == '\n' {
+			return line, true
+		}
+	case translateCRLF:
+		if by == '\n' && len(line) > 0 && line[len(line)-1] == '\r' {
+			return line[:len(line)-1], true
+		}
+	case translateCR:
+		if by == '\r' {
+			return line, true
+		}
+	}
+	return append(line, by), false
+}
+
+// Write any buffered output.
+func (ch *tclChannel) flush() error {
+	if ch.buffer == nil {
+		return nil
+	}
+	return ch.buffer.Flush()
+}
+
+// Read one byte from channel, returns false at end of file.
+func (ch *tclChannel) readByte() (byte, bool, error) {
+	input := make([]byte, 1)
+	for {
+		n, err := ch.reader.Read(input)
+		if n == 1 {
+			return input[0], true, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+	}
+}
+
+// Read until delimiter, delimiter is included in result.
+func (ch *tclChannel) readDelim(delim byte) (string, bool, error) {
+	buffer := []byte{}
+	for {
+		by, ok, err := ch.readByte()
+		if err != nil {
+			return "", false, err
+		}
+		if !ok {
+			return string(buffer), true, nil
+		}
+		buffer = append(buffer, by)
+		if by == delim {
+			return string(buffer), false, nil
+		}
+	}
+}
+
+// Read a TCL word, either a braced string, quoted string, or text up to
+// next blank. Leading blanks are skipped.
+func (ch *tclChannel) readToken() (string, bool, error) {
+	buffer := []byte{}
+	level := 0
+	quote := false
+	escape := false
+	for {
+		by, ok, err := ch.readByte()
+		if err != nil {
+			return "", false, err
+		}
+		if !ok {
+			return string(buffer), true, nil
+		}
+
+		// Skip leading blanks.
+		if len(buffer) == 0 {
+			switch by {
+			case ' ', '\t', '\n', '\r':
+				continue
+			case '{':
+				level = 1
+				buffer = append(buffer, by)
+				continue
+			case '"':
+				quote = true
+				buffer = append(buffer, by)
+				continue
+			}
+		}
+
+		// Blanks end the word if not in braces or quotes.
+		if level == 0 && !quote && !escape && strings.ContainsRune(" \t\n\r", rune(by)) {
+			return string(buffer), false, nil
+		}
+
+		buffer = append(buffer, by)
+		switch {
+		case escape:
+			escape = false
+		case by == '\\':
+			escape = true
+		case level != 0 && by == '{':
+			level++
+		case level != 0 && by == '}':
+			level--
+			if level == 0 {
+				return string(buffer), false, nil
+			}
+		case quote && by == '"':
+			return string(buffer), false, nil
+		}
 	}
-	return t.SetResult(tcl.RetOk, string(buffer[:n]))
 }
 
 // Get a string from a channel.
@@ -235,27 +748,69 @@ func cmdGets(t *tcl.Tcl, args []string) int {
 		return t.SetResult(tcl.RetError, "no channel given")
 	}
 
-	file, ok := files.channels[args[1]]
+	ch, ok := files.channels[args[1]]
 	if !ok {
 		return t.SetResult(tcl.RetError, "file "+args[1]+" not opened")
 	}
 
-	buffer := ""
-	input := make([]byte, 1)
+	if ch.reader == nil {
+		return t.SetResult(tcl.RetError, "channel "+args[1]+" not opened for reading")
+	}
+
+	// Non-blocking channels return -1 if no full line is available.
+	if ch.config.nonBlocking {
+		line, ok, eof := ch.async.line()
+		if eof {
+			files.eof[args[1]] = true
+		}
+		if !ok {
+			if len(args) < 3 {
+				return t.SetResult(tcl.RetOk, "")
+			}
+			t.SetVarValue(args[2], "")
+			return t.SetResult(tcl.RetOk, "-1")
+		}
+		if len(args) < 3 {
+			return t.SetResult(tcl.RetOk, line)
+		}
+		t.SetVarValue(args[2], line)
+		return t.SetResult(tcl.RetOk, tcl.ConvertNumberToString(len(line), 10))
+	}
+
+	line := []byte{}
 	for {
-		n, rerr := file.Read(input)
-		if rerr != nil {
-			return t.SetResult(tcl.RetError, "read error "+rerr.Error())
+		by, ok, err := ch.readByte()
+		if err != nil {
+			return t.SetResult(tcl.RetError, "read error "+err.Error())
 		}
-		if n == 0 {
+
+		// At end of file return last partial line, or -1 if nothing read.
+		if !ok {
 			files.eof[args[1]] = true
-			return t.SetResult(tcl.RetOk, "")
+			if len(line) != 0 {
+				break
+			}
+			if len(args) < 3 {
+				return t.SetResult(tcl.RetOk, "")
+			}
+			t.SetVarValue(args[2], "")
+			return t.SetResult(tcl.RetOk, "-1")
+		}
+
+		// Newline following carriage return was already counted.
+		if ch.config.skipLF {
+			ch.config.skipLF = false
+			if by == '\n' {
+				continue
+			}
 		}
-		if input[0] == '\n' {
+		var done bool
+		line, done = ch.endOfLine(by, line)
+		if done {
 			break
 		}
-		buffer += string(input[0])
 	}
+	buffer := ch.decodeChars(line)
 
 	if len(args) < 3 {
 		return t.SetResult(tcl.RetOk, buffer)
@@ -266,8 +821,9 @@ func cmdGets(t *tcl.Tcl, args []string) int {
 
 // Write a string to a channel.
 func cmdPuts(t *tcl.Tcl, args []string) int {
+	usage := "puts ?-nonewline ?-channel channel ?channel text"
 	if len(args) < 2 {
-		return t.SetResult(tcl.RetError, "puts ?-nonewline ?file text")
+		return t.SetResult(tcl.RetError, usage)
 	}
 
 	files, ok := t.Data["file"].(*tclFileData)
@@ -276,20 +832,43 @@ func cmdPuts(t *tcl.Tcl, args []string) int {
 	}
 
 	noNewline := false
-	file := files.channels["stdout"]
+	channel := "stdout"
 	i := 1
-	if args[i] == "-nonewline" {
-		noNewline = true
-		i++
+outer:
+	// Options are only valid if followed by the text.
+	for ; (i + 1) < len(args); i++ {
+		switch args[i] {
+		case "-nonewline":
+			noNewline = true
+		case "-channel":
+			i++
+			if (i + 1) >= len(args) {
+				return t.SetResult(tcl.RetError, usage)
+			}
+			channel = args[i]
+		default:
+			break outer
+		}
 	}
 
-	if len(args) > (i + 1) {
-		ok := false
-		file, ok = files.channels[args[i]]
-		if !ok {
-			return t.SetResult(tcl.RetError, "file "+args[i]+" not opened")
-		}
+	// Channel given as argument before text.
+	switch len(args) - i {
+	case 1:
+	case 2:
+		channel = args[i]
 		i++
+	default:
+		return t.SetResult(tcl.RetError, usage)
+	}
+
+	ch, ok := files.channels[channel]
+	if !ok {
+		return t.SetResult(tcl.RetError, "file "+channel+" not opened")
+	}
+
+	out := ch.output(t)
+	if out == nil {
+		return t.SetResult(tcl.RetError, "channel "+channel+" not opened for writing")
 	}
 
 	text := args[i]
@@ -297,32 +876,50 @@ func cmdPuts(t *tcl.Tcl, args []string) int {
 		text += "\n"
 	}
 
-	_, err := file.WriteString(text)
+	err := ch.write(out, text)
 	if err != nil {
 		return t.SetResult(tcl.RetError, err.Error())
 	}
 	return t.SetResult(tcl.RetOk, "")
 }
 
-// Read a file in and run it as a command.
+// Read a file in and run it as a command, returns result of last command.
+// Relative names not found are looked for in each directory of auto_path.
 func cmdSource(t *tcl.Tcl, args []string) int {
 	if len(args) < 2 {
 		return t.SetResult(tcl.RetError, "source file ?args")
 	}
 	text, err := os.ReadFile(args[1])
+	if err != nil && !filepath.IsAbs(args[1]) {
+		if ret, path := t.GetVarValue("::auto_path"); ret == tcl.RetOk {
+			for _, dir := range t.ParseArgs(path) {
+				if dir == "" {
+					continue
+				}
+				if text, err = os.ReadFile(filepath.Join(dir, args[1])); err == nil {
+					break
+				}
+			}
+		}
+	}
 	if err != nil {
-		panic(err)
+		return t.SetResult(tcl.RetError, "couldn't read file \""+args[1]+"\": "+err.Error())
 	}
 	t.SetVarValue("argv0", args[1])
 	if len(args) > 2 {
 		t.SetVarValue("argv", strings.Join(args[2:], " "))
 		t.SetVarValue("argc", tcl.ConvertNumberToString(len(args[2:]), 10))
 	}
+
+	// Return in file ends the file, result holds any error message.
 	err = t.EvalString(string(text))
-	if err != nil {
-		return t.SetResult(tcl.RetError, err.Error())
+	switch {
+	case errors.Is(err, tcl.ErrExit):
+		return tcl.RetExit
+	case err != nil:
+		return tcl.RetError
 	}
-	return t.SetResult(tcl.RetOk, "")
+	return tcl.RetOk
 }
 
 // Seek or Tell command.
@@ -334,14 +931,22 @@ func cmdSeek(t *tcl.Tcl, args []string, name string) int {
 		panic("invalid data type file extension")
 	}
 
-	if len(args) < 1 {
+	if len(args) < 2 {
 		return t.SetResult(tcl.RetError, "no channel given")
 	}
 
-	file, ok := files.channels[args[1]]
+	ch, ok := files.channels[args[1]]
 	if !ok {
 		return t.SetResult(tcl.RetError, "file "+args[1]+" not opened")
 	}
+
+	// Standard channels, sockets and pipes can't seek, tell returns -1.
+	if ch.file == nil || isStdChannel(args[1]) {
+		if name == "tell" {
+			return t.SetResult(tcl.RetOk, "-1")
+		}
+		return t.SetResult(tcl.RetError, "channel "+args[1]+" does not support seeking")
+	}
 	origin := io.SeekCurrent
 	offset := 0
 	if name == "seek" {
@@ -368,8 +973,11 @@ func cmdSeek(t *tcl.Tcl, args []string, name string) int {
 			}
 		}
 	}
-	position, err := file.Seek(int64(offset), origin)
+	position, err := ch.file.Seek(int64(offset), origin)
 	if err != nil {
+		if name == "tell" {
+			return t.SetResult(tcl.RetOk, "-1")
+		}
 		return t.SetResult(tcl.RetError, err.Error())
 	}
 	if name == "seek" {
@@ -389,9 +997,20 @@ func cmdFlush(t *tcl.Tcl, args []string) int {
 		panic("invalid data type file extension")
 	}
 
-	err := files.channels[args[1]].Sync()
-	if err != nil {
+	ch, ok := files.channels[args[1]]
+	if !ok {
+		return t.SetResult(tcl.RetError, "file "+args[1]+" not opened")
+	}
+
+	if err := ch.flush(); err != nil {
 		return t.SetResult(tcl.RetError, err.Error())
 	}
+
+	if ch.file != nil && ch.writer != nil {
+		err := ch.file.Sync()
+		if err != nil {
+			return t.SetResult(tcl.RetError, err.Error())
+		}
+	}
 	return t.SetResult(tcl.RetOk, "")
 }
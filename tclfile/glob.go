@@ -0,0 +1,247 @@
+/*
+ * TCL  Glob command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tclfile
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tcl "github.com/rcornwell/tinyTCL/tcl"
+)
+
+// File types accepted by glob -types, any one must match.
+var globTypes = map[string]fs.FileMode{
+	"b": fs.ModeDevice,
+	"c": fs.ModeCharDevice,
+	"d": fs.ModeDir,
+	"l": fs.ModeSymlink,
+	"p": fs.ModeNamedPipe,
+	"s": fs.ModeSocket,
+}
+
+// Permissions accepted by glob -types, current user must have all of them.
+var globPerms = map[string]fs.FileMode{
+	"r": 0o444,
+	"w": 0o222,
+	"x": 0o111,
+}
+
+// Return files matching patterns.
+func cmdGlob(t *tcl.Tcl, args []string) int {
+	usage := "glob ?-directory dir ?-nocomplain ?-path pathPrefix ?-tails ?-types typeList ?-- pattern ?pattern ..."
+	dir := ""
+	prefix := ""
+	hasPrefix := false
+	nocomplain := false
+	tails := false
+	types := []string{}
+	i := 1
+outer:
+	for ; i < len(args); i++ {
+		switch args[i] {
+		case "-directory":
+			i++
+			if i >= len(args) {
+				return t.SetResult(tcl.RetError, usage)
+			}
+			dir = args[i]
+		case "-nocomplain":
+			nocomplain = true
+		case "-path":
+			i++
+			if i >= len(args) {
+				return t.SetResult(tcl.RetError, usage)
+			}
+			prefix = args[i]
+			hasPrefix = true
+		case "-tails":
+			tails = true
+		case "-types":
+			i++
+			if i >= len(args) {
+				return t.SetResult(tcl.RetError, usage)
+			}
+			for _, typ := range t.ParseArgs(args[i]) {
+				_, isType := globTypes[typ]
+				_, isPerm := globPerms[typ]
+				if typ != "f" && !isType && !isPerm {
+					return t.SetResult(tcl.RetError, "bad argument to -types \""+typ+"\"")
+				}
+				types = append(types, typ)
+			}
+		case "--":
+			i++
+			break outer
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				return t.SetResult(tcl.RetError, "bad option \""+args[i]+"\": must be -directory, -nocomplain, -path, -tails, -types, or --")
+			}
+			break outer
+		}
+	}
+
+	if i >= len(args) {
+		return t.SetResult(tcl.RetError, usage)
+	}
+	if dir != "" && hasPrefix {
+		return t.SetResult(tcl.RetError, "\"-directory\" cannot be used with \"-path\"")
+	}
+
+	// Tails are relative to directory, or directory holding path prefix.
+	base := dir
+	if hasPrefix {
+		base = filepath.Dir(prefix)
+	}
+	if tails && dir == "" && !hasPrefix {
+		return t.SetResult(tcl.RetError, "\"-tails\" must be used with either \"-directory\" or \"-path\"")
+	}
+
+	res := []string{}
+	for _, pattern := range args[i:] {
+		switch {
+		case dir != "":
+			pattern = filepath.Join(dir, pattern)
+		case hasPrefix:
+			pattern = prefix + pattern
+		}
+
+		var matches []string
+		var err error
+		if strings.Contains(pattern, "**") {
+			matches, err = globRecursive(pattern)
+		} else {
+			matches, err = filepath.Glob(pattern)
+		}
+		if err != nil {
+			return t.SetResult(tcl.RetError, err.Error())
+		}
+		for _, match := range matches {
+			if !globMatchTypes(match, types) {
+				continue
+			}
+			if tails {
+				if rel, err := filepath.Rel(base, match); err == nil {
+					match = rel
+				}
+			}
+			res = append(res, tcl.StringEscape(match))
+		}
+	}
+
+	if len(res) == 0 && !nocomplain {
+		return t.SetResult(tcl.RetError, "no files matched glob pattern \""+strings.Join(args[i:], " ")+"\"")
+	}
+	return t.SetResult(tcl.RetOk, strings.Join(res, " "))
+}
+
+// Return files matching pattern where a ** component matches any number of
+// directories. The tree below the part of pattern without wildcards is walked.
+func globRecursive(pattern string) ([]string, error) {
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+	fixed := 0
+	for fixed < len(parts) && !strings.ContainsAny(parts[fixed], "*?[") {
+		fixed++
+	}
+	root := "."
+	if fixed > 0 {
+		root = strings.Join(parts[:fixed], "/")
+		if root == "" {
+			root = "/"
+		}
+	}
+
+	matches := []string{}
+	err := filepath.WalkDir(root, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if globMatchParts(parts, strings.Split(filepath.ToSlash(path), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// Match path components against pattern components, ** matches any number.
+func globMatchParts(pattern []string, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if globMatchParts(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(pattern[0], parts[0])
+	return ok && globMatchParts(pattern[1:], parts[1:])
+}
+
+// Check if file matches any of the types and all of the permissions.
+func globMatchTypes(name string, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	linfo, err := os.Lstat(name)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		info = linfo
+	}
+
+	typeGiven := false
+	typeMatch := false
+	for _, typ := range types {
+		if perm, ok := globPerms[typ]; ok {
+			if !canAccess(name, info, perm) {
+				return false
+			}
+			continue
+		}
+		typeGiven = true
+		switch typ {
+		case "f":
+			typeMatch = typeMatch || info.Mode().IsRegular()
+		case "b":
+			typeMatch = typeMatch || info.Mode()&(fs.ModeDevice|fs.ModeCharDevice) == fs.ModeDevice
+		case "l":
+			typeMatch = typeMatch || linfo.Mode()&fs.ModeSymlink != 0
+		default:
+			typeMatch = typeMatch || info.Mode()&globTypes[typ] != 0
+		}
+	}
+	return !typeGiven || typeMatch
+}
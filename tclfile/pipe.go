@@ -0,0 +1,97 @@
+/*
+ * TCL  open pipe to command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tclfile
+
+import (
+	"io"
+	"os"
+	"os/exec"
+
+	tcl "github.com/rcornwell/tinyTCL/tcl"
+)
+
+// Open a pipe to command. Reading from the channel reads the output of the
+// command, writing to the channel writes to the input of the command.
+func openPipe(t *tcl.Tcl, files *tclFileData, command string, mode int) int {
+	argv := t.ParseArgs(command)
+	if len(argv) == 0 || argv[0] == "" {
+		return t.SetResult(tcl.RetError, "illegal use of | in command")
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stderr = t.ErrorOutput()
+	ch := &tclChannel{cmd: cmd}
+
+	if mode&os.O_WRONLY == 0 {
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return t.SetResult(tcl.RetError, "couldn't create pipe: "+err.Error())
+		}
+		ch.reader = out
+		ch.cmdOutput = out
+	} else {
+		cmd.Stdout = t.Output()
+	}
+
+	if mode&(os.O_WRONLY|os.O_RDWR) != 0 {
+		in, err := cmd.StdinPipe()
+		if err != nil {
+			return t.SetResult(tcl.RetError, "couldn't create pipe: "+err.Error())
+		}
+		ch.writer = in
+	}
+
+	if err := cmd.Start(); err != nil {
+		return t.SetResult(tcl.RetError, "couldn't execute \""+argv[0]+"\": "+err.Error())
+	}
+
+	// Named by process so names can't collide with files named by descriptor.
+	channel := "pipe" + tcl.ConvertNumberToString(cmd.Process.Pid, 10)
+	files.channels[channel] = ch
+	files.eof[channel] = false
+	return t.SetResult(tcl.RetOk, channel)
+}
+
+// Close one or both directions of pipe, once both are closed wait for the
+// command to finish.
+func (ch *tclChannel) closePipe(read bool, write bool) error {
+	if write && ch.writer != nil {
+		if in, ok := ch.writer.(io.Closer); ok {
+			_ = in.Close()
+		}
+		ch.writer = nil
+	}
+
+	// Closing output lets command finish if it is still writing.
+	if read && ch.cmdOutput != nil {
+		_ = ch.cmdOutput.Close()
+		ch.reader = nil
+	}
+	if ch.reader != nil || ch.writer != nil {
+		return nil
+	}
+	return ch.cmd.Wait()
+}
@@ -0,0 +1,155 @@
+/*
+ * TCL  socket command.
+ *
+ * Copyright 2024, Richard Cornwell
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package tclfile
+
+import (
+	"net"
+	"strings"
+
+	tcl "github.com/rcornwell/tinyTCL/tcl"
+)
+
+// Open a client socket, or a server socket with -server.
+func cmdSocket(t *tcl.Tcl, args []string) int {
+	usage := "socket ?-myaddr addr? ?-myport port? host port or socket -server command ?-myaddr addr? port"
+	command := ""
+	myAddr := ""
+	myPort := ""
+	i := 1
+outer:
+	for ; i < len(args); i++ {
+		switch args[i] {
+		case "-server":
+			i++
+			if i >= len(args) {
+				return t.SetResult(tcl.RetError, usage)
+			}
+			command = args[i]
+		case "-myaddr":
+			i++
+			if i >= len(args) {
+				return t.SetResult(tcl.RetError, usage)
+			}
+			myAddr = args[i]
+		case "-myport":
+			i++
+			if i >= len(args) {
+				return t.SetResult(tcl.RetError, usage)
+			}
+			myPort = args[i]
+		default:
+			break outer
+		}
+	}
+
+	files, ok := t.Data["file"].(*tclFileData)
+	if !ok {
+		panic("invalid data type file extension")
+	}
+
+	if command == "" {
+		if (i + 2) != len(args) {
+			return t.SetResult(tcl.RetError, usage)
+		}
+		return openClientSocket(t, files, args[i], args[i+1], myAddr, myPort)
+	}
+
+	if myPort != "" || (i+1) != len(args) {
+		return t.SetResult(tcl.RetError, usage)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(myAddr, args[i]))
+	if err != nil {
+		return t.SetResult(tcl.RetError, "couldn't open socket: "+err.Error())
+	}
+
+	channel := files.newSocketName()
+	files.channels[channel] = &tclChannel{listener: listener}
+	files.eof[channel] = false
+	go acceptConnections(t, listener, command)
+	return t.SetResult(tcl.RetOk, channel)
+}
+
+// Connect to host and port, returns name of new channel.
+func openClientSocket(t *tcl.Tcl, files *tclFileData, host string, port string, myAddr string, myPort string) int {
+	dialer := net.Dialer{}
+	if myAddr != "" || myPort != "" {
+		local, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(myAddr, myPort))
+		if err != nil {
+			return t.SetResult(tcl.RetError, "couldn't open socket: "+err.Error())
+		}
+		dialer.LocalAddr = local
+	}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return t.SetResult(tcl.RetError, "couldn't open socket: "+err.Error())
+	}
+
+	channel := files.newSocketName()
+	files.channels[channel] = newSocketChannel(conn)
+	files.eof[channel] = false
+	return t.SetResult(tcl.RetOk, channel)
+}
+
+// Return unique name for a socket channel, skipping names of channels
+// transferred from other interpreters.
+func (files *tclFileData) newSocketName() string {
+	for {
+		name := "sock" + tcl.ConvertNumberToString(files.sockets, 10)
+		files.sockets++
+		if _, ok := files.channels[name]; !ok {
+			return name
+		}
+	}
+}
+
+// Accept connections until the listener is closed. Each connection is
+// handed to the interpreter as an event which runs the accept command.
+func acceptConnections(t *tcl.Tcl, listener net.Listener, command string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		t.PostEvent(func(t *tcl.Tcl) int {
+			files, ok := t.Data["file"].(*tclFileData)
+			if !ok {
+				panic("invalid data type file extension")
+			}
+			channel := files.newSocketName()
+			files.channels[channel] = newSocketChannel(conn)
+			files.eof[channel] = false
+
+			addr, port := "", 0
+			if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+				addr = tcpAddr.IP.String()
+				port = tcpAddr.Port
+			}
+			script := []string{command, channel, addr, tcl.ConvertNumberToString(port, 10)}
+			return t.EvalGlobal(strings.Join(script, " "))
+		})
+	}
+}
@@ -32,14 +32,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	tcl "github.com/rcornwell/tinyTCL/tcl"
 )
 
 var funcMap = map[string]func(*tcl.Tcl, []string) int{
-	"atime":       fileType,      // name
+	"atime":       fileType,      // name ?time
 	"channels":    fileChannels,  // ?pattern
-	"copy":        fileCopy,      //  -force -- source target
+	"copy":        fileCopy,      //  -force -preserve -- source target
 	"cwd":         fileCwd,       // dir
 	"delete":      fileDelete,    //  -force -- pathname???
 	"dir":         fileDir,       // ?dir
@@ -51,6 +52,7 @@ var funcMap = map[string]func(*tcl.Tcl, []string) int{
 	"isfile":      fileType,      // name
 	"join":        fileJoin,      // name name?
 	"mkdir":       fileMkdir,     // dir?
+	"mtime":       fileType,      // name ?time
 	"readable":    fileAccess,    // name
 	"rename":      fileRename,    // -force -- source target
 	"rootname":    filePath,      // name
@@ -102,7 +104,18 @@ func fileChannels(t *tcl.Tcl, args []string) int { // ?pattern
 		panic("invalid data type file extension")
 	}
 
+	// Standard channels are always listed first.
+	for _, ch := range stdChannels {
+		if len(args) > 2 && tcl.Match(args[2], ch, false, len(ch)) != 1 {
+			continue
+		}
+		res = append(res, ch)
+	}
+
 	for ch := range fil.channels {
+		if isStdChannel(ch) {
+			continue
+		}
 		if len(args) > 2 && tcl.Match(args[2], ch, false, len(ch)) != 1 {
 			continue
 		}
@@ -113,21 +126,29 @@ func fileChannels(t *tcl.Tcl, args []string) int { // ?pattern
 }
 
 // Copy one file to another.
-func fileCopy(t *tcl.Tcl, args []string) int { //  -force -- source target
-	if len(args) < 2 {
-		return t.SetResult(tcl.RetError, "file copy ?-force file ?file ?target")
-	}
-
+func fileCopy(t *tcl.Tcl, args []string) int { //  -force -preserve -- source target
+	usage := "file copy ?-force ?-preserve file ?file ?target"
 	force := false
+	preserve := false
 
 	i := 2
-	if args[i] == "-force" {
-		force = true
-		i++
+outer:
+	for ; i < len(args); i++ {
+		switch args[i] {
+		case "-force":
+			force = true
+		case "-preserve":
+			preserve = true
+		case "--":
+			i++
+			break outer
+		default:
+			break outer
+		}
 	}
 
 	if len(args) < (i + 2) {
-		return t.SetResult(tcl.RetError, "file copy ?-force file ?file ?target")
+		return t.SetResult(tcl.RetError, usage)
 	}
 
 	// Check if last argument is a directory.
@@ -146,7 +167,7 @@ func fileCopy(t *tcl.Tcl, args []string) int { //  -force -- source target
 	}
 
 	for len(args) > (i + 1) {
-		err = copyFile(args[i], target, dir, force)
+		err = copyFile(args[i], target, dir, force, preserve)
 		if err != nil {
 			return t.SetResult(tcl.RetError, err.Error())
 		}
@@ -155,8 +176,9 @@ func fileCopy(t *tcl.Tcl, args []string) int { //  -force -- source target
 	return t.SetResult(tcl.RetOk, "")
 }
 
-// Copy a file to file or directory.
-func copyFile(src string, dst string, dir bool, force bool) error {
+// Copy a file to file or directory. If preserve is set, the permissions and
+// times of source are copied to the new file.
+func copyFile(src string, dst string, dir bool, force bool, preserve bool) error {
 	source, err := os.Stat(src)
 	if err != nil {
 		return err
@@ -184,10 +206,24 @@ func copyFile(src string, dst string, dir bool, force bool) error {
 	if err != nil {
 		return err
 	}
-	defer destFile.Close()
 
 	_, err = io.Copy(destFile, sourceFile)
-	return err
+	cerr := destFile.Close()
+	if err != nil {
+		return err
+	}
+	if cerr != nil {
+		return cerr
+	}
+
+	if preserve {
+		err = os.Chmod(dst, source.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(dst, accessTime(source), source.ModTime())
+	}
+	return nil
 }
 
 // Delete a file.
@@ -233,9 +269,31 @@ func filePath(t *tcl.Tcl, args []string) int { // name
 
 // Returns 1 if file is of requested type, 0 if not.
 func fileType(t *tcl.Tcl, args []string) int { // name
-	if len(args) > 3 {
+	if len(args) > 4 || (len(args) == 4 && args[1] != "atime" && args[1] != "mtime") {
 		return t.SetResult(tcl.RetError, "file "+args[1]+" name")
 	}
+
+	// Set access or modification time, other time is left unchanged.
+	if len(args) == 4 {
+		seconds, _, ok := tcl.ConvertStringToNumber(args[3], 10, 0)
+		if !ok {
+			return t.SetResult(tcl.RetError, "expected integer but got \""+args[3]+"\"")
+		}
+		var atime, mtime time.Time
+		if args[1] == "atime" {
+			atime = time.Unix(int64(seconds), 0)
+		} else {
+			mtime = time.Unix(int64(seconds), 0)
+		}
+		if err := os.Chtimes(args[2], atime, mtime); err != nil {
+			if os.IsNotExist(err) {
+				return t.SetResult(tcl.RetError, "file "+args[2]+" does not exist")
+			}
+			return t.SetResult(tcl.RetError, err.Error())
+		}
+		return t.SetResult(tcl.RetOk, args[3])
+	}
+
 	info, err := os.Lstat(args[2])
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -245,9 +303,11 @@ func fileType(t *tcl.Tcl, args []string) int { // name
 	}
 
 	switch args[1] {
-	case "atime", "mtime":
-		time := info.ModTime()
-		return t.SetResult(tcl.RetOk, tcl.ConvertNumberToString(int(time.Unix()), 10))
+	case "atime":
+		return t.SetResult(tcl.RetOk, tcl.ConvertNumberToString(int(accessTime(info).Unix()), 10))
+
+	case "mtime":
+		return t.SetResult(tcl.RetOk, tcl.ConvertNumberToString(int(info.ModTime().Unix()), 10))
 
 	case "isdirectory":
 		if info.IsDir() {